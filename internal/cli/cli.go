@@ -2,8 +2,12 @@ package cli
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -11,11 +15,31 @@ import (
 	"time"
 
 	"github.com/kanopy-platform/cdnvalidator/internal/config"
+	"github.com/kanopy-platform/cdnvalidator/internal/github"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	"github.com/kanopy-platform/cdnvalidator/internal/kubernetes"
 	"github.com/kanopy-platform/cdnvalidator/internal/server"
 	"github.com/kanopy-platform/cdnvalidator/pkg/aws/cloudfront"
+	"github.com/kanopy-platform/cdnvalidator/pkg/aws/s3"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// AuthModeTrustUpstream trusts tokens validated by a forward auth proxy
+	AuthModeTrustUpstream = "trust-upstream"
+	// AuthModeJWKS verifies token signatures against a JWKS
+	AuthModeJWKS = "jwks"
+	// AuthModeOIDC verifies tokens against the keys of a discovered OIDC issuer
+	AuthModeOIDC = "oidc"
+
+	// KubernetesAuthModeTokenReview authenticates ServiceAccount tokens with the TokenReview API
+	KubernetesAuthModeTokenReview = "tokenreview"
+	// KubernetesAuthModeJWKS verifies ServiceAccount tokens offline against the cluster's keys
+	KubernetesAuthModeJWKS = "jwks"
 )
 
 type RootCommand struct{}
@@ -29,15 +53,45 @@ func NewRootCommand() *cobra.Command {
 		RunE:              root.runE,
 	}
 
+	cmd.AddCommand(newConfigCommand())
+
 	cmd.PersistentFlags().String("log-level", "info", "Configure log level")
 	cmd.PersistentFlags().String("listen-address", ":8080", "Server listen address")
 	cmd.PersistentFlags().String("auth-cookie", "auth_token", "Auth cookie name")
 	cmd.PersistentFlags().String("auth-header", "", "Header name for the auth token, takes precedence over auth-cookie when set.")
+	cmd.PersistentFlags().StringSlice("csrf-allowed-origins", []string{}, "Origins other than the server allowed to submit cookie authenticated requests, e.g. https://portal.example.com")
+	cmd.PersistentFlags().String("api-key-header", "X-API-Key", "Header name for service account API keys, empty disables API keys")
+	cmd.PersistentFlags().String("auth-mode", AuthModeTrustUpstream, "Token authentication mode, one of: trust-upstream, jwks, oidc")
+	cmd.PersistentFlags().String("jwks-url", "", "URL of the JWKS used to verify tokens when auth-mode is jwks")
+	cmd.PersistentFlags().String("jwks-file", "", "Local JWKS file used to verify tokens when auth-mode is jwks")
+	cmd.PersistentFlags().String("oidc-issuer", "", "OIDC issuer URL used for discovery and iss validation when auth-mode is oidc")
+	cmd.PersistentFlags().StringSlice("oidc-audience", []string{}, "Accepted token audiences, any one must be present in the aud claim when set")
+	cmd.PersistentFlags().Duration("oidc-clock-skew", time.Minute, "Allowed clock skew when validating exp and nbf claims")
+	cmd.PersistentFlags().String("kubernetes-issuer", "", "Issuer of Kubernetes ServiceAccount tokens, enables ServiceAccount token authentication")
+	cmd.PersistentFlags().String("kubernetes-auth-mode", KubernetesAuthModeTokenReview, "Kubernetes ServiceAccount token authentication mode, one of: tokenreview, jwks")
+	cmd.PersistentFlags().StringSlice("kubernetes-audience", []string{}, "Accepted Kubernetes ServiceAccount token audiences")
+	cmd.PersistentFlags().String("kubernetes-jwks-url", "", "URL of the cluster JWKS when kubernetes-auth-mode is jwks, discovered from kubernetes-issuer when empty")
+	cmd.PersistentFlags().String("kubeconfig", "", "Kubeconfig used for TokenReview, the in-cluster config is used when empty")
+	cmd.PersistentFlags().String("github-actions-issuer", "", "Issuer of GitHub Actions OIDC tokens e.g. https://token.actions.githubusercontent.com, enables GitHub Actions authentication")
+	cmd.PersistentFlags().StringSlice("github-actions-audience", []string{}, "Accepted GitHub Actions token audiences, required with github-actions-issuer")
+	cmd.PersistentFlags().String("tls-cert-file", "", "TLS certificate file, serves HTTPS when set with tls-key-file")
+	cmd.PersistentFlags().String("tls-key-file", "", "TLS private key file")
+	cmd.PersistentFlags().String("tls-client-ca-file", "", "CA bundle used to verify client certificates, enables client certificate authentication")
+	cmd.PersistentFlags().String("client-cert-header", "", "Header carrying the URL encoded PEM client certificate forwarded by a TLS terminating proxy")
+	cmd.PersistentFlags().StringSlice("trusted-proxies", []string{}, "CIDRs of proxies trusted to forward client certificates")
 	cmd.PersistentFlags().String("config-file", "", "Configuration file name")
-	cmd.PersistentFlags().String("aws-region", "us-east-1", "AWS region for Cloudfront")
-	cmd.PersistentFlags().String("aws-key", "", "AWS static credential key for Cloudfront")
-	cmd.PersistentFlags().String("aws-secret", "", "AWS static credential secret for Cloudfront")
+	cmd.PersistentFlags().String("config-dir", "", "Directory of configuration files (*.yaml) merged into one configuration, instead of config-file")
+	cmd.PersistentFlags().String("config-source", "", "Configuration file path or URL, one of: a path, file://, http(s):// or s3://bucket/key, instead of config-file")
+	cmd.PersistentFlags().Duration("config-poll-interval", 30*time.Second, "Interval at which http(s) and s3 configuration sources are polled for changes")
+	cmd.PersistentFlags().String("grants-file", "", "File persisting temporary grants issued through the API, empty disables temporary grants")
+	cmd.PersistentFlags().Duration("grant-expiry-interval", time.Minute, "Interval at which expired grants are removed and reported")
+	cmd.PersistentFlags().Duration("max-grant-duration", 24*time.Hour, "Maximum time window of temporary grants, from notBefore or their creation until they expire, 0 is unlimited")
+	cmd.PersistentFlags().String("aws-region", "us-east-1", "AWS region for Cloudfront and s3 configuration sources")
+	cmd.PersistentFlags().String("aws-key", "", "AWS static credential key for Cloudfront and s3 configuration sources")
+	cmd.PersistentFlags().String("aws-secret", "", "AWS static credential secret for Cloudfront and s3 configuration sources")
 	cmd.PersistentFlags().String("timeout", "30s", "Timeout")
+	cmd.PersistentFlags().Bool("cloudfront-discovery", false, "Discover vanity distributions from the cdnvalidator/name and cdnvalidator/prefix tags of Cloudfront distributions")
+	cmd.PersistentFlags().Duration("cloudfront-discovery-interval", 5*time.Minute, "Interval at which vanity distributions are discovered from Cloudfront tags")
 
 	return cmd
 }
@@ -68,15 +122,6 @@ func (c *RootCommand) runE(cmd *cobra.Command, args []string) error {
 
 	log.Printf("Starting server on %s\n", addr)
 
-	// build config
-	config := config.New()
-	if viper.GetString("config-file") == "" {
-		return errors.New("no config file specified")
-	}
-	if err := config.Watch(viper.GetString("config-file")); err != nil {
-		return err
-	}
-
 	// build cloudfront client
 	cloudfrontClient, err := cloudfront.New(
 		cloudfront.WithAWSRegion(viper.GetString("aws-region")),
@@ -87,12 +132,100 @@ func (c *RootCommand) runE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	s, err := server.New(
-		config,
-		cloudfrontClient,
+	// build config
+	configOpts := []config.Option{}
+	if file := viper.GetString("grants-file"); file != "" {
+		store, err := config.NewGrantStore(file)
+		if err != nil {
+			return err
+		}
+		configOpts = append(configOpts, config.WithGrantStore(store), config.WithMaxGrantDuration(viper.GetDuration("max-grant-duration")))
+	}
+
+	config := config.New(configOpts...)
+
+	// discovered distributions are merged with the configuration loaded below
+	discovery := viper.GetBool("cloudfront-discovery")
+	if discovery {
+		if viper.GetDuration("cloudfront-discovery-interval") <= 0 {
+			return errors.New("cloudfront-discovery-interval must be positive")
+		}
+
+		discovered, err := cloudfrontClient.DiscoverDistributions(cmd.Context())
+		if err := applyDiscoveredDistributions(config, discovered, err); err != nil {
+			return err
+		}
+	}
+
+	switch file, dir, src := viper.GetString("config-file"), viper.GetString("config-dir"), viper.GetString("config-source"); {
+	case countSet(file, dir, src) > 1:
+		return errors.New("only one of config-file, config-dir or config-source may be specified")
+	case src != "":
+		source, err := newConfigSource(src)
+		if err != nil {
+			return err
+		}
+		if err := config.WatchSource(source); err != nil {
+			return err
+		}
+	case dir != "":
+		if err := config.WatchDir(dir); err != nil {
+			return err
+		}
+	case file != "":
+		if err := config.Watch(file); err != nil {
+			return err
+		}
+	default:
+		return errors.New("no config file specified")
+	}
+	config.WatchGrantExpiry(viper.GetDuration("grant-expiry-interval"))
+
+	if discovery {
+		go cloudfrontClient.WatchDistributions(cmd.Context(), viper.GetDuration("cloudfront-discovery-interval"), func(discovered []cloudfront.DiscoveredDistribution, err error) {
+			if err := applyDiscoveredDistributions(config, discovered, err); err != nil {
+				log.Error(err)
+			}
+		})
+	}
+
+	verifier, err := newVerifier(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, clientCAs, err := newTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	serverOpts := []server.Option{
 		server.WithAuthCookieName(viper.GetString("auth-cookie")),
 		server.WithAuthHeaderName(viper.GetString("auth-header")),
-	)
+		server.WithJWTVerifier(verifier),
+		server.WithAPIKeyHeaderName(viper.GetString("api-key-header")),
+		server.WithClientCAs(clientCAs),
+		server.WithForwardedClientCertificates(viper.GetString("client-cert-header"), viper.GetStringSlice("trusted-proxies")),
+		server.WithCSRFAllowedOrigins(viper.GetStringSlice("csrf-allowed-origins")),
+	}
+
+	if viper.GetString("kubernetes-issuer") != "" {
+		kubernetesAuth, err := newKubernetesAuthenticator(cmd.Context())
+		if err != nil {
+			return err
+		}
+		serverOpts = append(serverOpts, server.WithTokenAuthenticator(kubernetesAuth))
+	}
+
+	if viper.GetString("github-actions-issuer") != "" {
+		githubAuth, err := newGitHubActionsAuthenticator(cmd.Context())
+		if err != nil {
+			return err
+		}
+		serverOpts = append(serverOpts, server.WithTokenAuthenticator(githubAuth))
+	}
+
+	s, err := server.New(config, cloudfrontClient, serverOpts...)
 	if err != nil {
 		return err
 	}
@@ -103,10 +236,17 @@ func (c *RootCommand) runE(cmd *cobra.Command, args []string) error {
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
 		Handler:      s,
+		TLSConfig:    tlsConfig,
 	}
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS(viper.GetString("tls-cert-file"), viper.GetString("tls-key-file"))
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil {
 			log.Infof("listen: %s", err)
 		}
 	}()
@@ -134,3 +274,252 @@ func (c *RootCommand) runE(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// countSet returns the number of values that are not empty
+func countSet(values ...string) int {
+	n := 0
+	for _, value := range values {
+		if value != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// newConfigSource builds the configuration source of a path or URL
+func newConfigSource(raw string) (config.Source, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config-source: %v", err)
+	}
+
+	interval := viper.GetDuration("config-poll-interval")
+	if interval <= 0 && u.Scheme != "" && u.Scheme != "file" {
+		return nil, errors.New("config-poll-interval must be positive")
+	}
+
+	switch u.Scheme {
+	case "":
+		return config.NewFileSource(raw), nil
+	case "file":
+		return config.NewFileSource(u.Path), nil
+	case "http", "https":
+		return config.NewHTTPSource(raw, interval), nil
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid config-source %s, expected s3://bucket/key", raw)
+		}
+
+		client, err := s3.New(
+			s3.WithAWSRegion(viper.GetString("aws-region")),
+			s3.WithStaticCredentials(viper.GetString("aws-key"), viper.GetString("aws-secret")),
+			s3.WithTimeout(viper.GetDuration("timeout")),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return config.NewS3Source(client, u.Host, key, interval), nil
+	}
+
+	return nil, fmt.Errorf("unsupported config-source scheme %s", u.Scheme)
+}
+
+// applyDiscoveredDistributions applies the outcome of a discovery to the
+// configuration.  Skipped distributions are logged, only a failed discovery
+// is returned.
+func applyDiscoveredDistributions(c *config.Config, discovered []cloudfront.DiscoveredDistribution, err error) error {
+	var invalidTags *cloudfront.InvalidTagsError
+	if errors.As(err, &invalidTags) {
+		log.Warn(err)
+	} else if err != nil {
+		return fmt.Errorf("error discovering distributions: %v", err)
+	}
+
+	if err := c.SetDiscoveredDistributions(discoveredDistributions(discovered)); err != nil {
+		log.Warnf("error applying discovered distributions: %v", err)
+	}
+
+	return nil
+}
+
+// discoveredDistributions returns the vanity distributions described by the
+// tags of Cloudfront distributions
+func discoveredDistributions(discovered []cloudfront.DiscoveredDistribution) map[string]*config.Distribution {
+	distributions := make(map[string]*config.Distribution, len(discovered))
+	for _, d := range discovered {
+		distributions[d.Name] = &config.Distribution{
+			ID:          d.DistributionID,
+			Prefixes:    d.Prefixes,
+			Description: d.Description,
+			Owners:      d.Owners,
+			Contact:     d.Contact,
+		}
+	}
+
+	return distributions
+}
+
+// newVerifier builds the token verifier for the configured auth-mode,
+// a nil verifier means tokens are trusted as validated upstream.
+func newVerifier(ctx context.Context) (*jwt.Verifier, error) {
+	opts := []jwt.VerifierOption{
+		jwt.WithAudience(viper.GetStringSlice("oidc-audience")...),
+		jwt.WithLeeway(viper.GetDuration("oidc-clock-skew")),
+	}
+
+	switch mode := viper.GetString("auth-mode"); mode {
+	case AuthModeTrustUpstream:
+		log.Warn("auth-mode trust-upstream: token signatures are not verified, a forward auth proxy must validate tokens")
+		return nil, nil
+	case AuthModeJWKS:
+		url := viper.GetString("jwks-url")
+		file := viper.GetString("jwks-file")
+
+		switch {
+		case url != "" && file != "":
+			return nil, errors.New("only one of jwks-url or jwks-file may be specified")
+		case url != "":
+			return jwt.NewVerifier(jwt.NewRemoteKeySet(url), opts...), nil
+		case file != "":
+			keySet, err := jwt.NewFileKeySet(file)
+			if err != nil {
+				return nil, fmt.Errorf("error loading jwks-file: %w", err)
+			}
+			return jwt.NewVerifier(keySet, opts...), nil
+		default:
+			return nil, errors.New("auth-mode jwks requires jwks-url or jwks-file")
+		}
+	case AuthModeOIDC:
+		issuer := viper.GetString("oidc-issuer")
+		if issuer == "" {
+			return nil, errors.New("auth-mode oidc requires oidc-issuer")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, viper.GetDuration("timeout"))
+		defer cancel()
+
+		return jwt.NewOIDCVerifier(ctx, issuer, opts...)
+	default:
+		return nil, fmt.Errorf("unknown auth-mode %q", mode)
+	}
+}
+
+// newTLSConfig builds the server TLS configuration, a nil config serves
+// plain HTTP.  The returned pool verifies client certificates either
+// presented during the handshake or forwarded by a trusted proxy.
+func newTLSConfig() (*tls.Config, *x509.CertPool, error) {
+	certFile := viper.GetString("tls-cert-file")
+	keyFile := viper.GetString("tls-key-file")
+	caFile := viper.GetString("tls-client-ca-file")
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, nil, errors.New("tls-cert-file and tls-key-file must be specified together")
+	}
+
+	var clientCAs *x509.CertPool
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error loading tls-client-ca-file: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, errors.New("error loading tls-client-ca-file: no certificates found")
+		}
+	}
+
+	if certFile == "" {
+		if clientCAs != nil && viper.GetString("client-cert-header") == "" {
+			return nil, nil, errors.New("tls-client-ca-file requires tls-cert-file or client-cert-header")
+		}
+		return nil, clientCAs, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if clientCAs != nil {
+		// callers without a certificate fall back to token authentication
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, clientCAs, nil
+}
+
+// newKubernetesAuthenticator builds the ServiceAccount token authenticator
+// for the configured kubernetes-auth-mode.
+func newKubernetesAuthenticator(ctx context.Context) (*kubernetes.Authenticator, error) {
+	issuer := viper.GetString("kubernetes-issuer")
+	audiences := viper.GetStringSlice("kubernetes-audience")
+
+	switch mode := viper.GetString("kubernetes-auth-mode"); mode {
+	case KubernetesAuthModeTokenReview:
+		restConfig, err := clientcmd.BuildConfigFromFlags("", viper.GetString("kubeconfig"))
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+		}
+		restConfig.Timeout = viper.GetDuration("timeout")
+
+		client, err := k8s.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		return kubernetes.New(issuer, kubernetes.WithTokenReview(client), kubernetes.WithAudiences(audiences...))
+	case KubernetesAuthModeJWKS:
+		opts := []jwt.VerifierOption{
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audiences...),
+			jwt.WithLeeway(viper.GetDuration("oidc-clock-skew")),
+		}
+
+		var verifier *jwt.Verifier
+		if url := viper.GetString("kubernetes-jwks-url"); url != "" {
+			verifier = jwt.NewVerifier(jwt.NewRemoteKeySet(url), opts...)
+		} else {
+			ctx, cancel := context.WithTimeout(ctx, viper.GetDuration("timeout"))
+			defer cancel()
+
+			var err error
+			if verifier, err = jwt.NewOIDCVerifier(ctx, issuer, opts...); err != nil {
+				return nil, err
+			}
+		}
+
+		return kubernetes.New(issuer, kubernetes.WithVerifier(verifier), kubernetes.WithAudiences(audiences...))
+	default:
+		return nil, fmt.Errorf("unknown kubernetes-auth-mode %q", mode)
+	}
+}
+
+// newGitHubActionsAuthenticator builds the authenticator of GitHub Actions
+// OIDC tokens, verified against the keys discovered from the issuer.
+func newGitHubActionsAuthenticator(ctx context.Context) (*github.Authenticator, error) {
+	issuer := viper.GetString("github-actions-issuer")
+	audiences := viper.GetStringSlice("github-actions-audience")
+
+	// the default audience is the repository owner's URL, requiring a
+	// dedicated audience keeps tokens minted for other services out
+	if len(audiences) == 0 {
+		return nil, errors.New("github-actions-issuer requires github-actions-audience")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, viper.GetDuration("timeout"))
+	defer cancel()
+
+	verifier, err := jwt.NewOIDCVerifier(ctx, issuer,
+		jwt.WithAudience(audiences...),
+		jwt.WithLeeway(viper.GetDuration("oidc-clock-skew")),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return github.New(issuer, verifier)
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// OutputText prints human readable lint results
+	OutputText = "text"
+	// OutputJSON prints lint results as a JSON config.LintReport
+	OutputJSON = "json"
+)
+
+var errInvalidConfig = errors.New("configuration is invalid")
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	validate := &cobra.Command{
+		Use:   "validate [file or directory]",
+		Short: "Validate the configuration offline and report warnings",
+		Long: `Validate loads the configuration file or directory like the server does and
+exits non-zero when it is invalid, reporting every unknown key.  It also
+warns about distributions nobody is entitled to, entitlements without
+distributions and suspicious prefixes.  The path defaults to --config-dir or
+--config-file.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          validateConfig,
+	}
+	validate.Flags().String("output", OutputText, "Output format, one of: text, json")
+	validate.Flags().Bool("fail-on-warnings", false, "Exit non-zero when warnings are reported")
+
+	schema := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration format",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := config.Schema()
+			if err != nil {
+				return err
+			}
+
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+
+	cmd.AddCommand(validate, schema)
+	return cmd
+}
+
+func validateConfig(cmd *cobra.Command, args []string) error {
+	path := viper.GetString("config-dir")
+	if path == "" {
+		path = viper.GetString("config-file")
+	}
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return errors.New("no config file specified")
+	}
+
+	report := config.Lint(path)
+	out := cmd.OutOrStdout()
+
+	switch format := viper.GetString("output"); format {
+	case OutputJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	case OutputText:
+		for _, e := range report.Errors {
+			fmt.Fprintf(out, "error: %s\n", e)
+		}
+		for _, w := range report.Warnings {
+			fmt.Fprintf(out, "warning: %s\n", w)
+		}
+		if report.Valid {
+			fmt.Fprintf(out, "%s is valid with %d warning(s)\n", path, len(report.Warnings))
+		}
+	default:
+		return fmt.Errorf("unknown output %q", format)
+	}
+
+	if !report.Valid {
+		return errInvalidConfig
+	}
+
+	if viper.GetBool("fail-on-warnings") && len(report.Warnings) > 0 {
+		return fmt.Errorf("configuration has %d warning(s)", len(report.Warnings))
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiKeySeparator separates the service account name from the secret in an API key
+const apiKeySeparator = ":"
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKey is a hashed API key of a service account
+type APIKey struct {
+	// Hash of the secret in bcrypt ($2a$, $2b$, $2y$) or argon2id ($argon2id$) format
+	Hash string `json:"hash"`
+	// Expires disables the key after the given time
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// ServiceAccount authenticates non interactive callers such as CI pipelines
+type ServiceAccount struct {
+	// Keys allow rotation by configuring the next key before removing the previous one
+	Keys         []APIKey `json:"keys"`
+	Entitlements []Grant  `json:"entitlements"`
+}
+
+type serviceAccountsMap map[string]*ServiceAccount
+
+func (k APIKey) expired(now time.Time) bool {
+	return k.Expires != nil && !now.Before(*k.Expires)
+}
+
+func (k APIKey) validate() error {
+	if strings.HasPrefix(k.Hash, "$argon2id$") {
+		_, err := parseArgon2idHash(k.Hash)
+		return err
+	}
+
+	if _, err := bcrypt.Cost([]byte(k.Hash)); err != nil {
+		return fmt.Errorf("unsupported key hash: %v", err)
+	}
+
+	return nil
+}
+
+func (k APIKey) matches(secret string) bool {
+	if strings.HasPrefix(k.Hash, "$argon2id$") {
+		h, err := parseArgon2idHash(k.Hash)
+		if err != nil {
+			return false
+		}
+
+		key := argon2.IDKey([]byte(secret), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+		return subtle.ConstantTimeCompare(key, h.key) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(k.Hash), []byte(secret)) == nil
+}
+
+// argon2idMaxMemory bounds the memory in KiB a configured argon2id hash may
+// require for every API key check, 1 GiB
+const argon2idMaxMemory = 1 << 20
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon2idHash parses the PHC string format
+// $argon2id$v=19$m=65536,t=3,p=4$<base64 salt>$<base64 key>
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	h := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %v", err)
+	}
+
+	switch {
+	case h.time < 1:
+		return nil, errors.New("invalid argon2id parameters: t must be at least 1")
+	case h.threads < 1:
+		return nil, errors.New("invalid argon2id parameters: p must be at least 1")
+	case h.memory < 8*uint32(h.threads):
+		return nil, fmt.Errorf("invalid argon2id parameters: m must be at least 8*p (%d)", 8*uint32(h.threads))
+	case h.memory > argon2idMaxMemory:
+		return nil, fmt.Errorf("invalid argon2id parameters: m must be at most %d", argon2idMaxMemory)
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return nil, errors.New("invalid argon2id key")
+	}
+
+	return h, nil
+}
+
+func validateServiceAccounts(accounts serviceAccountsMap, distributions distributionsMap) error {
+	for name, account := range accounts {
+		if name == "" || strings.Contains(name, apiKeySeparator) {
+			return fmt.Errorf("error parsing configuration: service account name %q must not be empty or contain %q", name, apiKeySeparator)
+		}
+
+		if account == nil || len(account.Keys) == 0 {
+			return fmt.Errorf("error parsing configuration: service account %s has no keys", name)
+		}
+
+		for i, key := range account.Keys {
+			if err := key.validate(); err != nil {
+				return fmt.Errorf("error parsing configuration: service account %s key %d: %v", name, i, err)
+			}
+		}
+
+		for _, grant := range account.Entitlements {
+			distribution, ok := distributions[grant.Distribution]
+			if !ok {
+				return fmt.Errorf("error parsing configuration: distribution %s in service account %s is not configured", grant.Distribution, name)
+			}
+
+			if err := grant.validate(distribution); err != nil {
+				return fmt.Errorf("error parsing configuration: distribution %s in service account %s: %v", grant.Distribution, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the identity of the service account owning
+// apiKey.  API keys have the format <service account>:<secret>.
+func (c *Config) AuthenticateAPIKey(apiKey string) (*core.Identity, error) {
+	name, secret, ok := strings.Cut(apiKey, apiKeySeparator)
+	if !ok || name == "" || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	account, ok := c.Snapshot().serviceAccounts[name]
+
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	for _, key := range account.Keys {
+		if key.expired(now) {
+			continue
+		}
+
+		if key.matches(secret) {
+			return &core.Identity{ServiceAccount: name}, nil
+		}
+	}
+
+	return nil, ErrInvalidAPIKey
+}
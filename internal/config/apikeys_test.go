@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testBcryptHash(t *testing.T, secret string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func testArgon2idHash(secret string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(secret), salt, 1, 64*1024, 2, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 64*1024, 1, 2,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	yaml := fmt.Sprintf(`---
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+  dis2:
+    id: "456"
+    prefix: "/bar"
+serviceAccounts:
+  ci-deployer:
+    keys:
+      - hash: %q
+        expires: "2000-01-01T00:00:00Z"
+      - hash: %q
+      - hash: %q
+        expires: "2999-01-01T00:00:00Z"
+    entitlements:
+      - dis1
+      - distribution: dis2
+        role: viewer
+`, testBcryptHash(t, "expired"), testBcryptHash(t, "current"), testArgon2idHash("next"))
+
+	config, err := NewTestConfigWithYaml([]byte(yaml))
+	require.NoError(t, err)
+
+	tests := []struct {
+		apiKey string
+		want   *core.Identity
+		err    error
+	}{
+		{apiKey: "ci-deployer:current", want: &core.Identity{ServiceAccount: "ci-deployer"}},
+		{apiKey: "ci-deployer:next", want: &core.Identity{ServiceAccount: "ci-deployer"}},
+		{apiKey: "ci-deployer:expired", err: ErrInvalidAPIKey},
+		{apiKey: "ci-deployer:wrong", err: ErrInvalidAPIKey},
+		{apiKey: "other:current", err: ErrInvalidAPIKey},
+		{apiKey: "current", err: ErrInvalidAPIKey},
+		{apiKey: "ci-deployer:", err: ErrInvalidAPIKey},
+	}
+
+	for _, test := range tests {
+		identity, err := config.AuthenticateAPIKey(test.apiKey)
+		assert.Equal(t, test.err, err, test.apiKey)
+		assert.Equal(t, test.want, identity, test.apiKey)
+	}
+
+	assert.Equal(t, map[string]Grants{
+		"dis1": {{Distribution: "dis1", Role: RoleInvalidator}},
+		"dis2": {{Distribution: "dis2", Role: RoleViewer}},
+	}, config.DistributionsFromClaims(&core.Identity{ServiceAccount: "ci-deployer"}))
+}
+
+func TestValidateServiceAccounts(t *testing.T) {
+	distributions := distributionsMap{
+		"dis1": {ID: "123", Prefix: "/foo"},
+	}
+	expires := time.Now()
+
+	tests := []struct {
+		accounts serviceAccountsMap
+		want     error
+	}{
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: testBcryptHash(t, "secret"), Expires: &expires}, {Hash: testArgon2idHash("secret")}}, Entitlements: []Grant{{Distribution: "dis1", Role: RoleInvalidator}}},
+			},
+			want: nil,
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci:bot": {Keys: []APIKey{{Hash: testBcryptHash(t, "secret")}}},
+			},
+			want: errors.New(`error parsing configuration: service account name "ci:bot" must not be empty or contain ":"`),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {},
+			},
+			want: errors.New("error parsing configuration: service account ci has no keys"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "plaintext"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: unsupported key hash: crypto/bcrypt: hashedSecret too short to be a bcrypted password"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=1,t=1$salt$key"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: invalid argon2id parameters: unexpected EOF"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=65536,t=0,p=2$c2FsdHNhbHQ$a2V5"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: invalid argon2id parameters: t must be at least 1"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=65536,t=1,p=0$c2FsdHNhbHQ$a2V5"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: invalid argon2id parameters: p must be at least 1"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=15,t=1,p=2$c2FsdHNhbHQ$a2V5"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: invalid argon2id parameters: m must be at least 8*p (16)"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=4194304,t=1,p=2$c2FsdHNhbHQ$a2V5"}}},
+			},
+			want: errors.New("error parsing configuration: service account ci key 0: invalid argon2id parameters: m must be at most 1048576"),
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: "$argon2id$v=19$m=16,t=1,p=2$c2FsdHNhbHQ$a2V5"}}},
+			},
+			want: nil,
+		},
+		{
+			accounts: serviceAccountsMap{
+				"ci": {Keys: []APIKey{{Hash: testBcryptHash(t, "secret")}}, Entitlements: []Grant{{Distribution: "dis2", Role: RoleInvalidator}}},
+			},
+			want: errors.New("error parsing configuration: distribution dis2 in service account ci is not configured"),
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, validateServiceAccounts(test.accounts, distributions))
+	}
+}
@@ -3,39 +3,119 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
 
-	"github.com/fsnotify/fsnotify"
-	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 )
 
-func New() *Config {
-	return &Config{}
+func New(opts ...Option) *Config {
+	c := &Config{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// distributionPrefix is a single prefix of a named distribution
+type distributionPrefix struct {
+	name         string
+	distribution *Distribution
+	prefix       string
 }
 
 // validateDistributions checks that the condition that
 // two distributions with the same distribution ID MUST NOT share the same prefix.
-// or in other terms, every pair of id,prefix (Distribution) must be unique
+// or in other terms, every pair of id,prefix must be unique.
+// Prefixes on the same distribution ID must not overlap either, unless the
+// nested distribution opts in with allowNesting, and neither may patterns
+// where an overlap can be proven.
 func validateDistributions(distributions distributionsMap) error {
-	uniqueMap := make(map[string]struct{})
+	return validateDistributionsAt(distributions, nil)
+}
+
+// validateDistributionsAt validates the distributions and names them with
+// the file and line they are defined at in errors
+func validateDistributionsAt(distributions distributionsMap, sources locations) error {
+	// sorted names report the same pair on every load
+	names := make([]string, 0, len(distributions))
+	for name := range distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	for _, value := range distributions {
-		hash := value.hashKey()
-		if _, ok := uniqueMap[hash]; ok {
-			return fmt.Errorf("error parsing configuration: distribution value duplicated id: %s prefix: %s", value.ID, value.Prefix)
+	prefixes := []distributionPrefix{}
+	for _, name := range names {
+		value := distributions[name]
+		if value.ID == "" {
+			return fmt.Errorf("error parsing configuration: distribution %s id is required", sources.name(name))
 		}
 
-		uniqueMap[hash] = struct{}{}
+		if value.Prefix == "" && len(value.Prefixes) == 0 && len(value.Patterns) == 0 {
+			return fmt.Errorf("error parsing configuration: distribution %s one of prefix, prefixes or patterns is required", sources.name(name))
+		}
+
+		if value.Prefix != "" && len(value.Prefixes) > 0 {
+			return fmt.Errorf("error parsing configuration: distribution %s sets both prefix and prefixes", sources.name(name))
+		}
+
+		if err := value.validateMetadata(); err != nil {
+			return fmt.Errorf("error parsing configuration: distribution %s %v", sources.name(name), err)
+		}
+
+		for _, prefix := range value.PathPrefixes() {
+			prefixes = append(prefixes, distributionPrefix{name: name, distribution: value, prefix: prefix})
+		}
 	}
 
-	return nil
+	uniqueMap := make(map[string]string)
+	for _, p := range prefixes {
+		hash := p.distribution.ID + p.prefix
+		if owner, ok := uniqueMap[hash]; ok {
+			return fmt.Errorf("error parsing configuration: distribution value duplicated id: %s prefix: %s%s", p.distribution.ID, p.prefix, sources.context(owner, p.name))
+		}
+
+		uniqueMap[hash] = p.name
+	}
+
+	for i, outer := range prefixes {
+		for _, inner := range prefixes[i+1:] {
+			if outer.distribution.ID != inner.distribution.ID {
+				continue
+			}
+
+			if PathHasPrefix(outer.prefix, inner.prefix) {
+				outer, inner = inner, outer
+			} else if !PathHasPrefix(inner.prefix, outer.prefix) {
+				continue
+			}
+
+			if outer.name == inner.name {
+				return fmt.Errorf("error parsing configuration: distribution %s prefix: %s overlaps its prefix: %s", sources.name(inner.name), inner.prefix, outer.prefix)
+			}
+
+			// the same prefix spelled with a trailing slash cannot be nested
+			samePrefix := PathHasPrefix(outer.prefix, inner.prefix) && PathHasPrefix(inner.prefix, outer.prefix)
+			if !inner.distribution.AllowNesting || samePrefix {
+				return fmt.Errorf("error parsing configuration: distribution %s prefix: %s overlaps distribution %s prefix: %s on id: %s", sources.name(inner.name), inner.prefix, sources.name(outer.name), outer.prefix, inner.distribution.ID)
+			}
+		}
+	}
+
+	return validatePatterns(names, distributions, sources)
 }
 
 func validateEntitlements(entitlements entitlementsMap, distributions distributionsMap) error {
-	for eName, distros := range entitlements {
-		for _, distro := range distros {
-			if _, ok := distributions[distro]; !ok {
-				return fmt.Errorf("error parsing configuration: distribution %s in entitlement %s is not configured", distro, eName)
+	for eName, grants := range entitlements {
+		for _, grant := range grants {
+			distribution, ok := distributions[grant.Distribution]
+			if !ok {
+				return fmt.Errorf("error parsing configuration: distribution %s in entitlement %s is not configured", grant.Distribution, eName)
+			}
+
+			if err := grant.validate(distribution); err != nil {
+				return fmt.Errorf("error parsing configuration: distribution %s in entitlement %s: %v", grant.Distribution, eName, err)
 			}
 		}
 	}
@@ -43,145 +123,210 @@ func validateEntitlements(entitlements entitlementsMap, distributions distributi
 	return nil
 }
 
-func (c *Config) parse(data []byte) error {
-	config := struct {
-		Distributions distributionsMap `json:"distributions"`
-		Entitlements  entitlementsMap  `json:"entitlements"`
-	}{}
-
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return err
+func validateUserEntitlements(users userEntitlements, distributions distributionsMap) error {
+	userMaps := map[string]entitlementsMap{
+		"sub":                users.Subject,
+		"email":              users.Email,
+		"preferred_username": users.PreferredUsername,
 	}
 
-	err := validateDistributions(config.Distributions)
-	if err != nil {
-		return err
+	for claim, entitlements := range userMaps {
+		for user, grants := range entitlements {
+			for _, grant := range grants {
+				distribution, ok := distributions[grant.Distribution]
+				if !ok {
+					return fmt.Errorf("error parsing configuration: distribution %s in user entitlement %s %s is not configured", grant.Distribution, claim, user)
+				}
+
+				if err := grant.validate(distribution); err != nil {
+					return fmt.Errorf("error parsing configuration: distribution %s in user entitlement %s %s: %v", grant.Distribution, claim, user, err)
+				}
+			}
+		}
 	}
 
-	err = validateEntitlements(config.Entitlements, config.Distributions)
-	if err != nil {
-		return err
+	return nil
+}
+
+func validateClaimMappings(claims []ClaimMapping) error {
+	uniqueMap := make(map[ClaimMapping]struct{})
+
+	for _, claim := range claims {
+		if claim.Path == "" {
+			return fmt.Errorf("error parsing configuration: claim path must not be empty")
+		}
+
+		if _, ok := uniqueMap[claim]; ok {
+			return fmt.Errorf("error parsing configuration: claim duplicated path: %s prefix: %s", claim.Path, claim.Prefix)
+		}
+
+		uniqueMap[claim] = struct{}{}
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return nil
+}
+
+// document is the format of a configuration file
+type document struct {
+	Distributions   distributionsMap       `json:"distributions"`
+	Entitlements    entitlementsMap        `json:"entitlements"`
+	Users           userEntitlements       `json:"users"`
+	ServiceAccounts serviceAccountsMap     `json:"serviceAccounts"`
+	Claims          []ClaimMapping         `json:"claims"`
+	Deny            []DenyRule             `json:"deny"`
+	Policies        []*Policy              `json:"policies"`
+	GitHubActions   []GitHubActionsBinding `json:"githubActions"`
+
+	// sources locate the distributions of documents merged from several files
+	sources locations
+}
 
-	c.distributions = make(distributionsMap)
-	for name, value := range config.Distributions {
-		c.distributions[name] = value
+// clone copies the distributions and policies of the document, which are
+// compiled during validation.  Documents already published in a snapshot
+// are read concurrently and must only be validated as a clone.
+func (d *document) clone() *document {
+	clone := *d
+	clone.Distributions = make(distributionsMap, len(d.Distributions))
+	for name, distribution := range d.Distributions {
+		copied := *distribution
+		copied.Patterns = append([]PathPattern(nil), distribution.Patterns...)
+		clone.Distributions[name] = &copied
 	}
 
-	c.entitlements = make(entitlementsMap)
-	for name, value := range config.Entitlements {
-		c.entitlements[name] = value
+	clone.Policies = make([]*Policy, 0, len(d.Policies))
+	for _, policy := range d.Policies {
+		copied := *policy
+		clone.Policies = append(clone.Policies, &copied)
 	}
 
-	return nil
+	return &clone
 }
 
-func (c *Config) load(file string) error {
-	data, err := os.ReadFile(file)
+func (c *Config) parse(data []byte) error {
+	config, err := decode(data)
 	if err != nil {
-		return err
+		return fmt.Errorf("error parsing configuration: %v", err)
 	}
 
-	if err := c.parse(data); err != nil {
+	return c.apply(config)
+}
+
+// decode strictly decodes a configuration file, rejecting unknown and
+// duplicated keys
+func decode(data []byte) (*document, error) {
+	if err := checkUnknownKeys(data); err != nil {
+		return nil, err
+	}
+
+	config := document{}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// apply validates the configuration merged with the discovered
+// distributions and replaces the active one with it
+func (c *Config) apply(config *document) error {
+	c.applyMu.Lock()
+	defer c.applyMu.Unlock()
+
+	if err := c.applyMerged(config, c.discovered); err != nil {
 		return err
 	}
 
+	c.static = config
 	return nil
 }
 
-func (c *Config) Watch(filePath string) error {
-	watcher, err := fsnotify.NewWatcher()
+// applyMerged validates the static configuration merged with discovered
+// distributions and replaces the active one with it, applyMu must be held
+func (c *Config) applyMerged(static *document, discovered distributionsMap) error {
+	config, err := validateMerged(static, discovered)
 	if err != nil {
 		return err
 	}
 
-	if err := watcher.Add(filePath); err != nil {
-		return err
+	c.snapshot.Store(newSnapshot(config, c.store))
+
+	return nil
+}
+
+// validateMerged returns the validated static configuration merged with
+// discovered distributions
+func validateMerged(static *document, discovered distributionsMap) (*document, error) {
+	merged, err := mergeDiscovered(static, discovered)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := c.load(filePath); err != nil {
-		return fmt.Errorf("error loading configuration: %v", err)
+	// static and discovered may be published in the active snapshot
+	config := merged.clone()
+
+	err = validateDistributionsAt(config.Distributions, config.sources)
+	if err != nil {
+		return nil, err
 	}
 
-	go c.watcher(filePath, watcher)
-	return nil
-}
+	err = validateEntitlements(config.Entitlements, config.Distributions)
+	if err != nil {
+		return nil, err
+	}
 
-func (c *Config) watcher(filePath string, watcher *fsnotify.Watcher) {
-	defer watcher.Close()
-	for {
-		select {
-		case event := <-watcher.Events:
-			reload := false
-
-			// Mounted files are symlinks. When the kubelet refreshes the file it is removing
-			// and adding a symlink.  Therefore, when we see a remove event we know that a reload
-			// needs to take place.
-			// https://kubernetes.io/docs/concepts/configuration/secret/#secret-files-permissions
-			if event.Op&fsnotify.Remove == fsnotify.Remove {
-				if err := watcher.Remove(event.Name); err != nil {
-					log.Errorf("error removing watcher from configuration: %v", err)
-				}
-				if err := watcher.Add(event.Name); err != nil {
-					log.Errorf("error re-watching configuration: %v", err)
-				}
-				reload = true
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				reload = true
-			}
+	err = validateUserEntitlements(config.Users, config.Distributions)
+	if err != nil {
+		return nil, err
+	}
 
-			if reload {
-				if err := c.load(event.Name); err != nil {
-					log.Errorf("error refreshing configuration: %v", err)
-				} else {
-					log.Info("configuration refreshed")
-				}
-			}
-		case err, ok := <-watcher.Errors:
-			log.Errorf("error on reload watcher: %v", err)
-			if !ok {
-				return
-			}
-		}
+	err = validateServiceAccounts(config.ServiceAccounts, config.Distributions)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// DistributionsFromClaims returns a lookup map of Distribution names
-func (c *Config) DistributionsFromClaims(claims []string) map[string]bool {
-	lookup := make(map[string]bool)
+	err = validateClaimMappings(config.Claims)
+	if err != nil {
+		return nil, err
+	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	err = validateDenyRules(config.Deny, config.Distributions)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, claim := range claims {
-		if distros, ok := c.entitlements[claim]; ok {
-			for _, distro := range distros {
-				if d := c.distributions[distro]; d != nil {
-					lookup[distro] = true
-				}
-			}
-		}
+	err = validatePolicies(config.Policies, config.Distributions)
+	if err != nil {
+		return nil, err
+	}
 
+	err = validateGitHubActionsBindings(config.GitHubActions, config.Distributions)
+	if err != nil {
+		return nil, err
 	}
 
-	return lookup
+	return config, nil
 }
 
-// Distribution returns a specific Distribution by name
-func (c *Config) Distribution(name string) *Distribution {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Config) load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
 
-	if entry, ok := c.distributions[name]; ok {
-		return &Distribution{
-			ID:     entry.ID,
-			Prefix: entry.Prefix,
-		}
+	return c.loadData(file, data)
+}
+
+// loadData parses the configuration loaded from source and applies it
+func (c *Config) loadData(source string, data []byte) error {
+	if err := c.parse(data); err != nil {
+		return err
 	}
 
+	c.loaded(source, contentHash([]string{source}, [][]byte{data}))
 	return nil
 }
+
+// Watch loads the configuration file and reloads it when it changes
+func (c *Config) Watch(filePath string) error {
+	return c.WatchSource(NewFileSource(filePath))
+}
@@ -4,29 +4,33 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"errors"
 
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/yaml"
 )
 
 func emptyConfig() *Config {
-	config := &Config{}
-
-	config.distributions = make(distributionsMap)
-	config.entitlements = make(entitlementsMap)
-
-	return config
+	return &Config{}
 }
 
 func setupConfig() *Config {
 	config := emptyConfig()
 
-	config.distributions["dis1"] = &Distribution{ID: "123", Prefix: "/foo"}
-	config.distributions["dis2"] = &Distribution{ID: "456", Prefix: "/bar"}
-	config.entitlements["grp1"] = []string{"dis1", "dis2"}
-	config.entitlements["grp2"] = []string{"dis2"}
+	config.snapshot.Store(newSnapshot(&document{
+		Distributions: distributionsMap{
+			"dis1": {ID: "123", Prefix: "/foo"},
+			"dis2": {ID: "456", Prefix: "/bar"},
+		},
+		Entitlements: entitlementsMap{
+			"grp1": {{Distribution: "dis1", Role: RoleInvalidator}, {Distribution: "dis2", Role: RoleInvalidator}},
+			"grp2": {{Distribution: "dis2", Role: RoleViewer}},
+		},
+	}, nil))
 
 	return config
 }
@@ -62,6 +66,14 @@ func TestValidateDistributions(t *testing.T) {
 			distros: repeatedDistributions,
 			want:    errors.New("error parsing configuration: distribution value duplicated id: 123 prefix: /foo"),
 		},
+		{
+			distros: distributionsMap{"dis1": {Prefix: "/foo"}},
+			want:    errors.New("error parsing configuration: distribution dis1 id is required"),
+		},
+		{
+			distros: distributionsMap{"dis1": {ID: "123"}},
+			want:    errors.New("error parsing configuration: distribution dis1 one of prefix, prefixes or patterns is required"),
+		},
 	}
 
 	for _, test := range tests {
@@ -69,6 +81,122 @@ func TestValidateDistributions(t *testing.T) {
 	}
 }
 
+func TestValidateDistributionOverlaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		distros distributionsMap
+		err     string
+	}{
+		{
+			name: "nested prefix",
+			distros: distributionsMap{
+				"outer": {ID: "123", Prefix: "/foo"},
+				"inner": {ID: "123", Prefix: "/foo/bar"},
+			},
+			err: "error parsing configuration: distribution inner prefix: /foo/bar overlaps distribution outer prefix: /foo on id: 123",
+		},
+		{
+			name: "nested prefix with trailing slash",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefix: "/foo/bar"},
+				"b": {ID: "123", Prefix: "/foo/"},
+			},
+			err: "error parsing configuration: distribution a prefix: /foo/bar overlaps distribution b prefix: /foo/ on id: 123",
+		},
+		{
+			name: "root prefix",
+			distros: distributionsMap{
+				"root": {ID: "123", Prefix: "/"},
+				"site": {ID: "123", Prefix: "/site"},
+			},
+			err: "error parsing configuration: distribution site prefix: /site overlaps distribution root prefix: / on id: 123",
+		},
+		{
+			name: "shared characters are not a shared segment",
+			distros: distributionsMap{
+				"foo":    {ID: "123", Prefix: "/foo"},
+				"foobar": {ID: "123", Prefix: "/foobar"},
+			},
+		},
+		{
+			name: "nested prefix on another id",
+			distros: distributionsMap{
+				"outer": {ID: "123", Prefix: "/foo"},
+				"inner": {ID: "456", Prefix: "/foo/bar"},
+			},
+		},
+		{
+			name: "nesting allowed",
+			distros: distributionsMap{
+				"outer": {ID: "123", Prefix: "/foo"},
+				"inner": {ID: "123", Prefix: "/foo/bar", AllowNesting: true},
+			},
+		},
+		{
+			name: "same prefix with trailing slash",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefix: "/foo", AllowNesting: true},
+				"b": {ID: "123", Prefix: "/foo/", AllowNesting: true},
+			},
+			err: "error parsing configuration: distribution a prefix: /foo overlaps distribution b prefix: /foo/ on id: 123",
+		},
+		{
+			name: "prefix and prefixes",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefix: "/foo", Prefixes: []string{"/bar"}},
+			},
+			err: "error parsing configuration: distribution a sets both prefix and prefixes",
+		},
+		{
+			name: "multiple prefixes",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefixes: []string{"/assets/team-a", "/docs/team-a"}},
+				"b": {ID: "123", Prefixes: []string{"/assets/team-b", "/docs/team-b"}},
+			},
+		},
+		{
+			name: "multiple prefixes overlapping another distribution",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefixes: []string{"/assets/team-a", "/docs"}},
+				"b": {ID: "123", Prefixes: []string{"/assets/team-b", "/docs/team-b"}},
+			},
+			err: "error parsing configuration: distribution b prefix: /docs/team-b overlaps distribution a prefix: /docs on id: 123",
+		},
+		{
+			name: "multiple prefixes overlapping each other",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefixes: []string{"/docs/team-a", "/docs"}, AllowNesting: true},
+			},
+			err: "error parsing configuration: distribution a prefix: /docs/team-a overlaps its prefix: /docs",
+		},
+		{
+			name: "multiple prefixes duplicating another distribution",
+			distros: distributionsMap{
+				"a": {ID: "123", Prefixes: []string{"/assets/team-a", "/docs"}},
+				"b": {ID: "123", Prefix: "/docs"},
+			},
+			err: "error parsing configuration: distribution value duplicated id: 123 prefix: /docs",
+		},
+		{
+			name: "nesting allowed on the outer distribution only",
+			distros: distributionsMap{
+				"outer": {ID: "123", Prefix: "/foo", AllowNesting: true},
+				"inner": {ID: "123", Prefix: "/foo/bar"},
+			},
+			err: "error parsing configuration: distribution inner prefix: /foo/bar overlaps distribution outer prefix: /foo on id: 123",
+		},
+	}
+
+	for _, test := range tests {
+		err := validateDistributions(test.distros)
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.EqualError(t, err, test.err, test.name)
+		}
+	}
+}
+
 func TestValidateEntitlements(t *testing.T) {
 	distributions := distributionsMap{
 		"dis1": {
@@ -90,10 +218,10 @@ func TestValidateEntitlements(t *testing.T) {
 			distributions: distributions,
 			entitlements: entitlementsMap{
 				"grp1": {
-					"dis1",
+					{Distribution: "dis1", Role: RoleInvalidator},
 				},
 				"grp2": {
-					"dis2",
+					{Distribution: "dis2", Role: RoleViewer},
 				},
 			},
 			want: nil,
@@ -102,15 +230,24 @@ func TestValidateEntitlements(t *testing.T) {
 			distributions: distributions,
 			entitlements: entitlementsMap{
 				"grp1": {
-					"dis1",
-					"dis2",
+					{Distribution: "dis1", Role: RoleInvalidator},
+					{Distribution: "dis2", Role: RoleInvalidator},
 				},
 				"grp2": {
-					"dis3",
+					{Distribution: "dis3", Role: RoleInvalidator},
 				},
 			},
 			want: errors.New("error parsing configuration: distribution dis3 in entitlement grp2 is not configured"),
 		},
+		{
+			distributions: distributions,
+			entitlements: entitlementsMap{
+				"grp1": {
+					{Distribution: "dis1", Role: "owner"},
+				},
+			},
+			want: errors.New(`error parsing configuration: distribution dis1 in entitlement grp1: unknown role "owner"`),
+		},
 	}
 
 	for _, test := range tests {
@@ -139,9 +276,9 @@ entitlements:
 	err := config.parse([]byte(yamlString))
 	assert.NoError(t, err)
 
-	assert.Equal(t, &Distribution{ID: "123", Prefix: "/foo"}, config.distributions["dis1"])
-	grp1 := config.entitlements["grp1"]
-	assert.Equal(t, []string{"dis1", "dis2"}, grp1)
+	assert.Equal(t, &Distribution{ID: "123", Prefix: "/foo"}, config.Snapshot().distributions["dis1"])
+	grp1 := config.Snapshot().entitlements["grp1"]
+	assert.Equal(t, []Grant{{Distribution: "dis1", Role: RoleInvalidator}, {Distribution: "dis2", Role: RoleInvalidator}}, grp1)
 
 	// assert concurrent access to config
 	ctx := context.Background()
@@ -154,7 +291,7 @@ entitlements:
 			}
 
 			// concurrently access values in distributions and entitlements
-			config.DistributionsFromClaims([]string{"grp1"})
+			config.DistributionsFromClaims(&core.Identity{Claims: []string{"grp1"}})
 			config.Distribution("dis2")
 		}
 	}()
@@ -174,6 +311,60 @@ entitlements:
 	cancel()
 }
 
+func TestParseStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{
+			name: "unknown distribution key",
+			yaml: `distributions:
+  dis1:
+    id: "123"
+    prefx: /foo
+`,
+			err: "error parsing configuration: unknown key distributions.dis1.prefx at line 4",
+		},
+		{
+			name: "unknown grant key",
+			yaml: `distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+entitlements:
+  grp1:
+    - distribution: dis1
+      pths: [/foo/bar]
+`,
+			err: "error parsing configuration: unknown key entitlements.grp1[0].pths at line 8",
+		},
+		{
+			name: "unknown section",
+			yaml: `polices: []
+`,
+			err: "error parsing configuration: unknown key polices at line 1",
+		},
+		{
+			name: "duplicated key",
+			yaml: `distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+    prefix: /bar
+`,
+			err: "error parsing configuration: error converting YAML to JSON: yaml: unmarshal errors:\n  line 5: key \"prefix\" already set in map",
+		},
+	}
+
+	for _, test := range tests {
+		config := emptyConfig()
+		err := config.parse([]byte(test.yaml))
+		assert.EqualError(t, err, test.err, test.name)
+		assert.Empty(t, config.Snapshot().distributions, test.name)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	config := emptyConfig()
 
@@ -198,20 +389,32 @@ func TestDistributionsFromClaims(t *testing.T) {
 
 	tests := []struct {
 		claims []string
-		want   map[string]bool
+		want   map[string]Grants
 	}{
 		{
 			claims: []string{"grp1"},
-			want:   map[string]bool{"dis1": true, "dis2": true},
+			want: map[string]Grants{
+				"dis1": {{Distribution: "dis1", Role: RoleInvalidator}},
+				"dis2": {{Distribution: "dis2", Role: RoleInvalidator}},
+			},
 		},
 		{
 			claims: []string{"grp2"},
-			want:   map[string]bool{"dis2": true},
+			want: map[string]Grants{
+				"dis2": {{Distribution: "dis2", Role: RoleViewer}},
+			},
+		},
+		{
+			claims: []string{"grp1", "grp2"},
+			want: map[string]Grants{
+				"dis1": {{Distribution: "dis1", Role: RoleInvalidator}},
+				"dis2": {{Distribution: "dis2", Role: RoleInvalidator}, {Distribution: "dis2", Role: RoleViewer}},
+			},
 		},
 	}
 
 	for _, test := range tests {
-		assert.Equal(t, test.want, config.DistributionsFromClaims(test.claims))
+		assert.Equal(t, test.want, config.DistributionsFromClaims(&core.Identity{Claims: test.claims}))
 	}
 }
 
@@ -222,3 +425,272 @@ func TestDistribution(t *testing.T) {
 	assert.Equal(t, want, config.Distribution("dis1"))
 	assert.Nil(t, config.Distribution("no-exists"))
 }
+
+func TestValidateClaimMappings(t *testing.T) {
+	tests := []struct {
+		claims []ClaimMapping
+		want   error
+	}{
+		{
+			claims: []ClaimMapping{{Path: "groups", Prefix: "group:"}, {Path: "groups"}},
+			want:   nil,
+		},
+		{
+			claims: []ClaimMapping{{Path: ""}},
+			want:   errors.New("error parsing configuration: claim path must not be empty"),
+		},
+		{
+			claims: []ClaimMapping{{Path: "scp", Prefix: "scope:"}, {Path: "scp", Prefix: "scope:"}},
+			want:   errors.New("error parsing configuration: claim duplicated path: scp prefix: scope:"),
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, validateClaimMappings(test.claims))
+	}
+}
+
+func TestClaimsFromToken(t *testing.T) {
+	token := &jwt.Claims{
+		Raw: map[string]interface{}{
+			"groups": []interface{}{"g1", "g2"},
+			"scp":    []interface{}{"s1"},
+			"roles":  "r1",
+			"realm_access": map[string]interface{}{
+				"roles": []interface{}{"kr1"},
+			},
+			"https://example.com/teams": []interface{}{"t1"},
+		},
+	}
+
+	tests := []struct {
+		yaml string
+		want []string
+	}{
+		{
+			// default mappings
+			yaml: `---
+distributions: {}
+`,
+			want: []string{"g1", "g2", "s1"},
+		},
+		{
+			yaml: `---
+claims:
+  - path: groups
+    prefix: "group:"
+  - path: scp
+    prefix: "scope:"
+  - path: roles
+  - path: realm_access.roles
+    prefix: "keycloak:"
+  - path: https://example.com/teams
+  - path: missing
+`,
+			want: []string{"group:g1", "group:g2", "scope:s1", "r1", "keycloak:kr1", "t1"},
+		},
+	}
+
+	for _, test := range tests {
+		config, err := NewTestConfigWithYaml([]byte(test.yaml))
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, config.ClaimsFromToken(token))
+	}
+
+	// unparsed config falls back to default mappings
+	assert.Equal(t, []string{"g1", "g2", "s1"}, New().ClaimsFromToken(token))
+}
+
+func TestUserEntitlements(t *testing.T) {
+	config, err := NewTestConfigWithYaml([]byte(`---
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+  dis2:
+    id: "456"
+    prefix: "/bar"
+entitlements:
+  grp1:
+    - dis1
+users:
+  sub:
+    00u123:
+      - dis1
+  email:
+    OnCall@Example.com:
+      - dis2
+  preferred_username:
+    jdoe:
+      - dis1
+      - dis2
+`))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		identity *core.Identity
+		want     map[string]Grants
+	}{
+		{
+			identity: &core.Identity{Subject: "00u123"},
+			want:     map[string]Grants{"dis1": {{Distribution: "dis1", Role: RoleInvalidator}}},
+		},
+		{
+			identity: &core.Identity{Email: "oncall@example.com"},
+			want:     map[string]Grants{"dis2": {{Distribution: "dis2", Role: RoleInvalidator}}},
+		},
+		{
+			identity: &core.Identity{PreferredUsername: "jdoe"},
+			want:     map[string]Grants{"dis1": {{Distribution: "dis1", Role: RoleInvalidator}}, "dis2": {{Distribution: "dis2", Role: RoleInvalidator}}},
+		},
+		{
+			identity: &core.Identity{Claims: []string{"grp1"}, Email: "oncall@example.com"},
+			want:     map[string]Grants{"dis1": {{Distribution: "dis1", Role: RoleInvalidator}}, "dis2": {{Distribution: "dis2", Role: RoleInvalidator}}},
+		},
+		{
+			// user entitlements are matched by their own claim only
+			identity: &core.Identity{Subject: "jdoe", Email: "00u123"},
+			want:     map[string]Grants{},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, config.DistributionsFromClaims(test.identity))
+	}
+
+	_, err = NewTestConfigWithYaml([]byte(`---
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+users:
+  email:
+    oncall@example.com:
+      - dis3
+`))
+	assert.Equal(t, errors.New("error parsing configuration: distribution dis3 in user entitlement email oncall@example.com is not configured"), err)
+}
+
+func TestGrantUnmarshal(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want []Grant
+		err  bool
+	}{
+		{
+			yaml: `[dis1, {distribution: dis2, role: viewer}, {distribution: dis3}]`,
+			want: []Grant{
+				{Distribution: "dis1", Role: RoleInvalidator},
+				{Distribution: "dis2", Role: RoleViewer},
+				{Distribution: "dis3", Role: RoleInvalidator},
+			},
+		},
+		{
+			yaml: `[[dis1]]`,
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		grants := []Grant{}
+		err := yaml.Unmarshal([]byte(test.yaml), &grants)
+		if test.err {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, grants)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	assert.True(t, RoleViewer.Allows(PermissionReadInvalidation))
+	assert.False(t, RoleViewer.Allows(PermissionCreateInvalidation))
+	assert.True(t, RoleInvalidator.Allows(PermissionCreateInvalidation))
+	assert.False(t, RoleInvalidator.Allows(PermissionManageDistribution))
+	assert.True(t, RoleAdmin.Allows(PermissionManageDistribution))
+
+	assert.True(t, RoleAdmin.Includes(RoleInvalidator))
+	assert.True(t, RoleInvalidator.Includes(RoleInvalidator))
+	assert.False(t, RoleInvalidator.Includes(RoleAdmin))
+
+	grants := Grants{{Distribution: "dis1", Role: RoleViewer}, {Distribution: "dis1", Role: RoleAdmin}}
+	assert.True(t, grants.Allows(PermissionManageDistribution))
+	assert.Equal(t, Grants{{Distribution: "dis1", Role: RoleAdmin}}, grants.With(PermissionCreateInvalidation))
+	assert.True(t, grants.AllowsRole(RoleAdmin))
+	assert.False(t, grants[:1].AllowsRole(RoleInvalidator))
+
+	// the window spans the grants, a side is unbounded when a grant is
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	later, latest := time.Unix(150, 0), time.Unix(300, 0)
+	notBefore, expires := Grants{{NotBefore: &later, Expires: &end}, {NotBefore: &start, Expires: &latest}}.Window()
+	assert.Equal(t, &start, notBefore)
+	assert.Equal(t, &latest, expires)
+
+	notBefore, expires = Grants{{NotBefore: &start, Expires: &end}, {}}.Window()
+	assert.Nil(t, notBefore)
+	assert.Nil(t, expires)
+}
+
+func TestPathHasPrefix(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   bool
+	}{
+		{path: "/foo", prefix: "/foo", want: true},
+		{path: "/foo/bar", prefix: "/foo", want: true},
+		{path: "/foo/*", prefix: "/foo", want: true},
+		{path: "/foo/bar", prefix: "/foo/", want: true},
+		{path: "/foobar", prefix: "/foo", want: false},
+		{path: "/foo*", prefix: "/foo", want: false},
+		{path: "/bar", prefix: "/foo", want: false},
+		{path: "/anything", prefix: "/", want: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, PathHasPrefix(test.path, test.prefix), "%s in %s", test.path, test.prefix)
+	}
+}
+
+func TestValidateGrantPaths(t *testing.T) {
+	distributions := distributionsMap{
+		"docs":   {ID: "123", Prefix: "/docs"},
+		"team-a": {ID: "456", Prefixes: []string{"/assets/team-a", "/docs/team-a"}},
+	}
+
+	tests := []struct {
+		grant Grant
+		want  error
+	}{
+		{
+			grant: Grant{Distribution: "docs", Role: RoleInvalidator, Paths: []string{"/docs/api", "/docs/guides"}},
+			want:  nil,
+		},
+		{
+			grant: Grant{Distribution: "docs", Role: RoleInvalidator, Paths: []string{"/docsy"}},
+			want:  errors.New("error parsing configuration: distribution docs in entitlement grp1: path /docsy is outside of prefix /docs"),
+		},
+		{
+			grant: Grant{Distribution: "docs", Role: RoleInvalidator, Paths: []string{"/docs/api/../../other"}},
+			want:  errors.New("error parsing configuration: distribution docs in entitlement grp1: path /docs/api/../../other must be an absolute clean path"),
+		},
+		{
+			grant: Grant{Distribution: "docs", Role: RoleInvalidator, Paths: []string{"docs/api"}},
+			want:  errors.New("error parsing configuration: distribution docs in entitlement grp1: path docs/api must be an absolute clean path"),
+		},
+		{
+			grant: Grant{Distribution: "team-a", Role: RoleInvalidator, Paths: []string{"/assets/team-a/img", "/docs/team-a"}},
+			want:  nil,
+		},
+		{
+			grant: Grant{Distribution: "team-a", Role: RoleInvalidator, Paths: []string{"/docs/team-b"}},
+			want:  errors.New("error parsing configuration: distribution team-a in entitlement grp1: path /docs/team-b is outside of prefixes [/assets/team-a /docs/team-a]"),
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, validateEntitlements(entitlementsMap{"grp1": {test.grant}}, distributions))
+	}
+}
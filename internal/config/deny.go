@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+)
+
+const denyWildcard = "*"
+
+// Selector matches the identities selected by any of Claims, Users,
+// ServiceAccounts or GitHubActions on any of Distributions.  Identities
+// match when no identity is selected and distributions match when the
+// list is empty.
+type Selector struct {
+	Claims []string `json:"claims,omitempty"`
+	// Users are matched by the sub, email or preferred_username claim of their token
+	Users *UserSelector `json:"users,omitempty"`
+	// ServiceAccounts are matched by the name of the service account of their API key
+	ServiceAccounts []string           `json:"serviceAccounts,omitempty"`
+	GitHubActions   []GitHubActionsRun `json:"githubActions,omitempty"`
+	Distributions   []string           `json:"distributions,omitempty"`
+}
+
+// UserSelector selects users like user entitlements do
+type UserSelector struct {
+	Subject           []string `json:"sub,omitempty"`
+	Email             []string `json:"email,omitempty"`
+	PreferredUsername []string `json:"preferred_username,omitempty"`
+}
+
+func (s *Selector) validate(distributions distributionsMap) error {
+	for _, d := range s.Distributions {
+		if _, ok := distributions[d]; !ok {
+			return fmt.Errorf("distribution %s is not configured", d)
+		}
+	}
+
+	for _, holder := range s.holders() {
+		if err := holder.validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := range s.GitHubActions {
+		if err := s.GitHubActions[i].validate(); err != nil {
+			return fmt.Errorf("github actions %d: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// holders returns the claims, users and service accounts selected
+func (s *Selector) holders() []GrantHolder {
+	holders := []GrantHolder{}
+	add := func(holderType string, names []string) {
+		for _, name := range names {
+			holders = append(holders, GrantHolder{Type: holderType, Name: name})
+		}
+	}
+
+	add(HolderClaim, s.Claims)
+	if s.Users != nil {
+		add(HolderSubject, s.Users.Subject)
+		add(HolderEmail, s.Users.Email)
+		add(HolderPreferredUsername, s.Users.PreferredUsername)
+	}
+	add(HolderServiceAccount, s.ServiceAccounts)
+
+	return holders
+}
+
+// selectsIdentities reports whether the selector narrows the identities it matches
+func (s *Selector) selectsIdentities() bool {
+	return len(s.holders()) > 0 || len(s.GitHubActions) > 0
+}
+
+func (s *Selector) matches(identity *core.Identity, distributionName string) bool {
+	if len(s.Distributions) > 0 && !contains(s.Distributions, distributionName) {
+		return false
+	}
+
+	if !s.selectsIdentities() {
+		return true
+	}
+
+	for _, holder := range s.holders() {
+		if holder.matches(identity) {
+			return true
+		}
+	}
+
+	for i := range s.GitHubActions {
+		if s.GitHubActions[i].matches(identity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DenyRule forbids invalidating paths regardless of entitlements
+type DenyRule struct {
+	Name string `json:"name"`
+	Selector
+	// Paths are exact paths or prefixes ending with a * wildcard e.g. /docs/legal/*
+	Paths []string `json:"paths"`
+}
+
+func (r *DenyRule) validate(distributions distributionsMap) error {
+	if !r.selectsIdentities() && len(r.Distributions) == 0 {
+		return errors.New("at least one of claims, users, serviceAccounts, githubActions or distributions is required")
+	}
+
+	if len(r.Paths) == 0 {
+		return errors.New("paths must not be empty")
+	}
+
+	if err := r.Selector.validate(distributions); err != nil {
+		return err
+	}
+
+	for _, p := range r.Paths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("path %s must be absolute", p)
+		}
+
+		if i := strings.Index(p, denyWildcard); i >= 0 && i != len(p)-1 {
+			return fmt.Errorf("path %s may only contain a wildcard at the end", p)
+		}
+	}
+
+	return nil
+}
+
+// deniedPaths returns the requested paths that would invalidate any path of the rule
+func (r *DenyRule) deniedPaths(paths []string) []string {
+	denied := []string{}
+
+	for _, path := range paths {
+		for _, pattern := range r.Paths {
+			if invalidationOverlaps(path, pattern) {
+				denied = append(denied, path)
+				break
+			}
+		}
+	}
+
+	return denied
+}
+
+// invalidationOverlaps reports whether two CloudFront invalidation paths
+// cover any common object.  A trailing * matches any suffix, so /docs/*
+// overlaps /docs/legal/* and /docs/legal/terms.html.
+func invalidationOverlaps(a, b string) bool {
+	aPrefix, aWildcard := strings.CutSuffix(a, denyWildcard)
+	bPrefix, bWildcard := strings.CutSuffix(b, denyWildcard)
+
+	switch {
+	case aWildcard && bWildcard:
+		return strings.HasPrefix(aPrefix, bPrefix) || strings.HasPrefix(bPrefix, aPrefix)
+	case aWildcard:
+		return strings.HasPrefix(b, aPrefix)
+	case bWildcard:
+		return strings.HasPrefix(a, bPrefix)
+	default:
+		return a == b
+	}
+}
+
+func validateDenyRules(rules []DenyRule, distributions distributionsMap) error {
+	uniqueMap := make(map[string]struct{})
+
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("error parsing configuration: deny rule name must not be empty")
+		}
+
+		if _, ok := uniqueMap[rule.Name]; ok {
+			return fmt.Errorf("error parsing configuration: deny rule duplicated name: %s", rule.Name)
+		}
+		uniqueMap[rule.Name] = struct{}{}
+
+		if err := rule.validate(distributions); err != nil {
+			return fmt.Errorf("error parsing configuration: deny rule %s: %v", rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidationOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "/docs/legal/terms.html", b: "/docs/legal/*", want: true},
+		{a: "/docs/*", b: "/docs/legal/*", want: true},
+		{a: "/docs/legal/*", b: "/docs/*", want: true},
+		{a: "/*", b: "/docs/legal/*", want: true},
+		{a: "/docs/leg*", b: "/docs/legal/*", want: true},
+		{a: "/docs/other/*", b: "/docs/legal/*", want: false},
+		{a: "/docs/guide.html", b: "/docs/legal/*", want: false},
+		{a: "/docs/legal", b: "/docs/legal/*", want: false},
+		{a: "/docs/legal", b: "/docs/legal", want: true},
+		{a: "/docs/*", b: "/docs/legal", want: true},
+		{a: "/docs/legal/terms.html", b: "/docs/legal", want: false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, invalidationOverlaps(test.a, test.b), "%s %s", test.a, test.b)
+	}
+}
+
+func TestDenyRuleValidation(t *testing.T) {
+	tests := []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: `
+deny:
+  - claims: [grp1]
+    paths: [/foo/*]
+`,
+			err: "error parsing configuration: deny rule name must not be empty",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    paths: [/foo/*]
+`,
+			err: "error parsing configuration: deny rule legal: at least one of claims, users, serviceAccounts, githubActions or distributions is required",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    claims: [grp1]
+`,
+			err: "error parsing configuration: deny rule legal: paths must not be empty",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    distributions: [dis3]
+    paths: [/foo/*]
+`,
+			err: "error parsing configuration: deny rule legal: distribution dis3 is not configured",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    githubActions:
+      - ref: refs/heads/main
+    paths: [/foo/*]
+`,
+			err: "error parsing configuration: deny rule legal: github actions 0: repository must not be empty",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    claims: [grp1]
+    paths: [foo/*]
+`,
+			err: "error parsing configuration: deny rule legal: path foo/* must be absolute",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    claims: [grp1]
+    paths: [/foo/*/legal]
+`,
+			err: "error parsing configuration: deny rule legal: path /foo/*/legal may only contain a wildcard at the end",
+		},
+		{
+			yaml: `
+deny:
+  - name: legal
+    claims: [grp1]
+    paths: [/foo/*]
+  - name: legal
+    distributions: [dis1]
+    paths: [/foo/legal]
+`,
+			err: "error parsing configuration: deny rule duplicated name: legal",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+` + test.yaml))
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestDeniedPaths(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  docs:
+    id: "123"
+    prefix: "/docs"
+  www:
+    id: "456"
+    prefix: "/"
+deny:
+  - name: legal-docs
+    claims: [all-engineers]
+    distributions: [docs]
+    paths:
+      - /docs/legal/*
+  - name: homepage
+    distributions: [www]
+    paths:
+      - /index.html
+  - name: releases
+    users:
+      email: [jane.doe@example.com]
+    serviceAccounts: [ci-deployer]
+    githubActions:
+      - repository: org/site
+        ref: refs/heads/feature/*
+    paths:
+      - /docs/releases/*
+`))
+	assert.NoError(t, err)
+
+	engineer := &core.Identity{Claims: []string{"all-engineers"}}
+	legal := &core.Identity{Claims: []string{"legal-team"}}
+	featureRun := &core.Identity{Issuer: GitHubActionsIssuer, Attributes: map[string]string{"repository": "org/site", "ref": "refs/heads/feature/x"}}
+	mainRun := &core.Identity{Issuer: GitHubActionsIssuer, Attributes: map[string]string{"repository": "org/site", "ref": "refs/heads/main"}}
+
+	tests := []struct {
+		identity     *core.Identity
+		distribution string
+		paths        []string
+		wantRule     string
+		wantDenied   []string
+	}{
+		{
+			identity:     engineer,
+			distribution: "docs",
+			paths:        []string{"/docs/guide/*", "/docs/legal/terms.html", "/docs/*"},
+			wantRule:     "legal-docs",
+			wantDenied:   []string{"/docs/legal/terms.html", "/docs/*"},
+		},
+		{
+			// rule does not apply to other claims
+			identity:     legal,
+			distribution: "docs",
+			paths:        []string{"/docs/legal/*"},
+		},
+		{
+			// rule does not apply to other distributions
+			identity:     engineer,
+			distribution: "www",
+			paths:        []string{"/docs/legal/*"},
+		},
+		{
+			// rules without claims apply to everyone
+			identity:     legal,
+			distribution: "www",
+			paths:        []string{"/about.html", "/*"},
+			wantRule:     "homepage",
+			wantDenied:   []string{"/*"},
+		},
+		{
+			// rules select users, service accounts and GitHub Actions runs as well as claims
+			identity:     &core.Identity{Email: "Jane.Doe@example.com"},
+			distribution: "docs",
+			paths:        []string{"/docs/releases/v1"},
+			wantRule:     "releases",
+			wantDenied:   []string{"/docs/releases/v1"},
+		},
+		{
+			identity:     &core.Identity{ServiceAccount: "ci-deployer"},
+			distribution: "docs",
+			paths:        []string{"/docs/releases/v1"},
+			wantRule:     "releases",
+			wantDenied:   []string{"/docs/releases/v1"},
+		},
+		{
+			identity:     featureRun,
+			distribution: "docs",
+			paths:        []string{"/docs/releases/v1"},
+			wantRule:     "releases",
+			wantDenied:   []string{"/docs/releases/v1"},
+		},
+		{
+			// rule does not apply to other workflow runs
+			identity:     mainRun,
+			distribution: "docs",
+			paths:        []string{"/docs/releases/v1"},
+		},
+	}
+
+	for _, test := range tests {
+		rule, denied := c.DeniedPaths(test.identity, test.distribution, test.paths)
+		assert.Equal(t, test.wantRule, rule)
+		assert.Equal(t, test.wantDenied, denied)
+	}
+}
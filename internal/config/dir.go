@@ -0,0 +1,259 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// kubernetesDataDir is swapped by the kubelet when a mounted ConfigMap changes
+const kubernetesDataDir = "..data"
+
+// locations map distribution names to the file and line defining them
+type locations map[distributionName]string
+
+// name returns the distribution name followed by its location when known
+func (l locations) name(name string) string {
+	if source, ok := l[name]; ok {
+		return fmt.Sprintf("%s (%s)", name, source)
+	}
+
+	return name
+}
+
+// context names two conflicting distributions with their locations when known
+func (l locations) context(a, b string) string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" in distributions %s and %s", l.name(a), l.name(b))
+}
+
+// isConfigFile reports whether a file in the configuration directory is loaded
+func isConfigFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// configFiles returns the configuration files of dir in lexical order
+func configFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// keyLines returns the line of every key in a section of a YAML document,
+// nested sections are addressed by their keys e.g. "users", "email"
+func keyLines(data []byte, section ...string) (map[string]int, error) {
+	root := yamlv3.Node{}
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]int)
+	if len(root.Content) == 0 {
+		return lines, nil
+	}
+
+	node := root.Content[0]
+	for _, key := range section {
+		node = mappingValue(node, key)
+	}
+
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return lines, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		lines[node.Content[i].Value] = node.Content[i].Line
+	}
+
+	return lines, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func mergeEntitlements(dst *entitlementsMap, src entitlementsMap) {
+	if *dst == nil {
+		*dst = make(entitlementsMap)
+	}
+
+	for name, grants := range src {
+		(*dst)[name] = append((*dst)[name], grants...)
+	}
+}
+
+// merge adds the document parsed from file.  Entitlements of the same claim
+// and list sections are combined while distributions and service accounts
+// must be defined by a single file.
+func (d *document) merge(other *document, file string, data []byte) error {
+	distributionLines, err := keyLines(data, "distributions")
+	if err != nil {
+		return err
+	}
+
+	accountLines, err := keyLines(data, "serviceAccounts")
+	if err != nil {
+		return err
+	}
+
+	if d.Distributions == nil {
+		d.Distributions = make(distributionsMap)
+		d.ServiceAccounts = make(serviceAccountsMap)
+		d.sources = make(locations)
+	}
+
+	for name, distribution := range other.Distributions {
+		source := fmt.Sprintf("%s:%d", file, distributionLines[name])
+		if existing, ok := d.sources[name]; ok {
+			return fmt.Errorf("error parsing configuration: distribution %s defined in %s and %s", name, existing, source)
+		}
+
+		d.Distributions[name] = distribution
+		d.sources[name] = source
+	}
+
+	for name, account := range other.ServiceAccounts {
+		if _, ok := d.ServiceAccounts[name]; ok {
+			return fmt.Errorf("error parsing configuration: service account %s redefined in %s:%d", name, file, accountLines[name])
+		}
+
+		d.ServiceAccounts[name] = account
+	}
+
+	mergeEntitlements(&d.Entitlements, other.Entitlements)
+	mergeEntitlements(&d.Users.Subject, other.Users.Subject)
+	mergeEntitlements(&d.Users.Email, other.Users.Email)
+	mergeEntitlements(&d.Users.PreferredUsername, other.Users.PreferredUsername)
+
+	d.Claims = append(d.Claims, other.Claims...)
+	d.Deny = append(d.Deny, other.Deny...)
+	d.Policies = append(d.Policies, other.Policies...)
+	d.GitHubActions = append(d.GitHubActions, other.GitHubActions...)
+
+	return nil
+}
+
+// loadDir merges every configuration file of dir into a single configuration
+func (c *Config) loadDir(dir string) error {
+	files, err := configFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("no configuration files found in %s", dir)
+	}
+
+	merged := document{}
+	contents := make([][]byte, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, data)
+
+		doc, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("error parsing configuration file %s: %v", file, err)
+		}
+
+		if err := merged.merge(doc, file, data); err != nil {
+			return err
+		}
+	}
+
+	if err := c.apply(&merged); err != nil {
+		return err
+	}
+
+	c.loaded(dir, contentHash(files, contents))
+	return nil
+}
+
+// WatchDir loads the configuration merged from the files in dir and reloads
+// it when files are added, changed or removed
+func (c *Config) WatchDir(dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	if err := c.reload(func() error { return c.loadDir(dir) }); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error loading configuration: %v", err)
+	}
+
+	go c.dirWatcher(dir, watcher)
+	return nil
+}
+
+func (c *Config) dirWatcher(dir string, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			name := filepath.Base(event.Name)
+			if event.Op == fsnotify.Chmod || (name != kubernetesDataDir && !isConfigFile(name)) {
+				continue
+			}
+
+			if err := c.reload(func() error { return c.loadDir(dir) }); err != nil {
+				log.Errorf("error refreshing configuration: %v", err)
+			} else {
+				log.WithField("file", event.Name).Info("configuration refreshed")
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("error on reload watcher: %v", err)
+		}
+	}
+}
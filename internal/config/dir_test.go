@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+}
+
+const teamAConfig = `distributions:
+  team-a:
+    id: "123"
+    prefix: /team-a
+entitlements:
+  team-a:
+    - team-a
+  admins:
+    - distribution: team-a
+      role: admin
+`
+
+const teamBConfig = `distributions:
+  team-b:
+    id: "123"
+    prefix: /team-b
+entitlements:
+  team-b:
+    - team-b
+  admins:
+    - distribution: team-b
+      role: admin
+`
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"team-a.yaml": teamAConfig,
+		"team-b.yml":  teamBConfig,
+		".hidden.yaml": `distributions:
+  team-a:
+    id: "456"
+    prefix: /hidden
+`,
+		"README.md": "not a configuration file",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.yaml"), 0o700))
+
+	c := New()
+	require.NoError(t, c.loadDir(dir))
+
+	assert.Equal(t, &Distribution{ID: "123", Prefix: "/team-a"}, c.Distribution("team-a"))
+	assert.Equal(t, &Distribution{ID: "123", Prefix: "/team-b"}, c.Distribution("team-b"))
+
+	// entitlements of the same claim are merged across files
+	lookup := c.DistributionsFromClaims(&core.Identity{Claims: []string{"admins"}})
+	assert.ElementsMatch(t, []string{"team-a", "team-b"}, keys(lookup))
+
+	empty := t.TempDir()
+	assert.EqualError(t, New().loadDir(empty), "no configuration files found in "+empty)
+}
+
+func TestLoadDirConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name: "duplicate vanity name",
+			files: map[string]string{
+				"a.yaml": teamAConfig,
+				"b.yaml": `# team b
+distributions:
+  team-b:
+    id: "123"
+    prefix: /team-b
+  team-a:
+    id: "456"
+    prefix: /team-a
+`,
+			},
+			err: "error parsing configuration: distribution team-a defined in {dir}/a.yaml:2 and {dir}/b.yaml:6",
+		},
+		{
+			name: "conflicting prefixes",
+			files: map[string]string{
+				"a.yaml": teamAConfig,
+				"b.yaml": `distributions:
+  team-a-docs:
+    id: "123"
+    prefix: /team-a/docs
+`,
+			},
+			err: "error parsing configuration: distribution team-a-docs ({dir}/b.yaml:2) prefix: /team-a/docs overlaps distribution team-a ({dir}/a.yaml:2) prefix: /team-a on id: 123",
+		},
+		{
+			name: "duplicate prefix",
+			files: map[string]string{
+				"a.yaml": teamAConfig,
+				"b.yaml": `distributions:
+  team-a-again:
+    id: "123"
+    prefix: /team-a
+`,
+			},
+			err: "error parsing configuration: distribution value duplicated id: 123 prefix: /team-a in distributions team-a ({dir}/a.yaml:2) and team-a-again ({dir}/b.yaml:2)",
+		},
+		{
+			name: "duplicate service account",
+			files: map[string]string{
+				"a.yaml": `serviceAccounts:
+  deployer:
+    keys: []
+    entitlements: []
+`,
+				"b.yaml": `serviceAccounts:
+  deployer:
+    keys: []
+    entitlements: []
+`,
+			},
+			err: "error parsing configuration: service account deployer redefined in {dir}/b.yaml:2",
+		},
+		{
+			name: "invalid file",
+			files: map[string]string{
+				"a.yaml": teamAConfig,
+				"b.yaml": "distributions: [",
+			},
+			err: "error parsing configuration file {dir}/b.yaml: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeConfigFiles(t, dir, test.files)
+
+		err := New().loadDir(dir)
+		assert.EqualError(t, err, replaceDir(test.err, dir), test.name)
+	}
+}
+
+func replaceDir(s, dir string) string {
+	return strings.ReplaceAll(s, "{dir}", dir)
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{"team-a.yaml": teamAConfig})
+
+	c := New()
+	require.NoError(t, c.WatchDir(dir))
+	assert.NotNil(t, c.Distribution("team-a"))
+	assert.Nil(t, c.Distribution("team-b"))
+
+	// added files are loaded
+	writeConfigFiles(t, dir, map[string]string{"team-b.yaml": teamBConfig})
+	assert.Eventually(t, func() bool { return c.Distribution("team-b") != nil }, 5*time.Second, 10*time.Millisecond)
+
+	// removed files are unloaded
+	require.NoError(t, os.Remove(filepath.Join(dir, "team-a.yaml")))
+	assert.Eventually(t, func() bool { return c.Distribution("team-a") == nil }, 5*time.Second, 10*time.Millisecond)
+
+	// an invalid change keeps the previous configuration
+	writeConfigFiles(t, dir, map[string]string{"team-c.yaml": teamBConfig})
+	time.Sleep(100 * time.Millisecond)
+	assert.NotNil(t, c.Distribution("team-b"))
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// mergeDiscovered returns the static configuration with the discovered
+// distributions added, located by the Cloudfront distribution they were
+// discovered on.  The static configuration is not modified.
+func mergeDiscovered(static *document, discovered distributionsMap) (*document, error) {
+	if len(discovered) == 0 {
+		return static, nil
+	}
+
+	merged := *static
+	merged.Distributions = make(distributionsMap, len(static.Distributions)+len(discovered))
+	merged.sources = make(locations, len(static.sources)+len(discovered))
+
+	for name, distribution := range static.Distributions {
+		merged.Distributions[name] = distribution
+	}
+	for name, source := range static.sources {
+		merged.sources[name] = source
+	}
+
+	// sorted names report the same conflict on every load
+	names := make([]string, 0, len(discovered))
+	for name := range discovered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		distribution := discovered[name]
+		if _, ok := static.Distributions[name]; ok {
+			return nil, fmt.Errorf("error parsing configuration: distribution %s is configured and discovered on cloudfront distribution %s", name, distribution.ID)
+		}
+
+		merged.Distributions[name] = distribution
+		merged.sources[name] = "discovered on cloudfront distribution " + distribution.ID
+	}
+
+	return &merged, nil
+}
+
+// SetDiscoveredDistributions replaces the distributions discovered from
+// Cloudfront tags, which are merged with the static configuration and
+// validated with it.  A distribution failing validation, or whose removal
+// fails it, is skipped and keeps its previous state while the others are
+// applied.  The skipped distributions are reported in the returned error.
+// Distributions set before the first configuration is loaded are validated
+// on their own and merged with it.
+func (c *Config) SetDiscoveredDistributions(distributions map[string]*Distribution) error {
+	discovered := make(distributionsMap, len(distributions))
+	for name, distribution := range distributions {
+		d := *distribution
+		d.Discovered = true
+		discovered[name] = &d
+	}
+
+	c.applyMu.Lock()
+	defer c.applyMu.Unlock()
+
+	if reflect.DeepEqual(c.discovered, discovered) {
+		return nil
+	}
+
+	static := c.static
+	if static == nil {
+		static = &document{}
+	}
+
+	var skipped []string
+	err := c.reload(func() error {
+		var applied distributionsMap
+		var config *document
+		applied, config, skipped = mergeValid(static, c.discovered, discovered)
+
+		hash, err := discoveredHash(applied)
+		if err != nil {
+			return err
+		}
+
+		if c.static != nil {
+			c.snapshot.Store(newSnapshot(config, c.store))
+		}
+		c.discovered = applied
+		c.discoveredLoaded(hash)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("skipped discovered distributions: %s", strings.Join(skipped, "; "))
+	}
+
+	return nil
+}
+
+// mergeValid applies the changes from the previous to the discovered
+// distributions which pass validation with static, one distribution at a
+// time, and returns the distributions applied with the validated merged
+// configuration and the errors of the changes skipped
+func mergeValid(static *document, previous, discovered distributionsMap) (distributionsMap, *document, []string) {
+	if config, err := validateMerged(static, discovered); err == nil {
+		return discovered, config, nil
+	}
+
+	names := []string{}
+	for name := range previous {
+		names = append(names, name)
+	}
+	for name := range discovered {
+		if _, ok := previous[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	valid := make(distributionsMap, len(previous))
+	for name, distribution := range previous {
+		valid[name] = distribution
+	}
+
+	skipped := []string{}
+	for _, name := range names {
+		distribution, ok := discovered[name]
+		if ok && reflect.DeepEqual(previous[name], distribution) {
+			continue
+		}
+
+		trial := make(distributionsMap, len(valid))
+		for n, d := range valid {
+			trial[n] = d
+		}
+		if ok {
+			trial[name] = distribution
+		} else {
+			delete(trial, name)
+		}
+
+		if _, err := validateMerged(static, trial); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		valid = trial
+	}
+
+	// the previous distributions were valid with static, and so is every
+	// change applied to them
+	config, _ := validateMerged(static, valid)
+
+	return valid, config, skipped
+}
+
+// discoveredHash hashes the discovered distributions
+func discoveredHash(discovered distributionsMap) (string, error) {
+	if len(discovered) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(discovered)
+	if err != nil {
+		return "", err
+	}
+
+	return contentHash([]string{"discovered"}, [][]byte{data}), nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDiscoveredDistributions(t *testing.T) {
+	c := New()
+
+	// distributions discovered before the first load are validated on their
+	// own and merged with it
+	err := c.SetDiscoveredDistributions(map[string]*Distribution{
+		"sandbox": {ID: "E1", Prefixes: []string{"/my/path"}},
+		"broken":  {ID: "E3"},
+	})
+	assert.EqualError(t, err, "skipped discovered distributions: broken: error parsing configuration: distribution broken (discovered on cloudfront distribution E3) one of prefix, prefixes or patterns is required")
+	assert.Nil(t, c.Distribution("sandbox"))
+
+	require.NoError(t, c.parse([]byte(teamAConfig+`  sandbox-team:
+    - sandbox
+`)))
+	assert.Equal(t, &Distribution{ID: "E1", Prefixes: []string{"/my/path"}, Discovered: true}, c.Distribution("sandbox"))
+	assert.Nil(t, c.Distribution("broken"))
+	assert.False(t, c.Distribution("team-a").Discovered)
+	assert.Contains(t, c.Snapshot().byClaim, "sandbox-team")
+
+	// unchanged distributions are not applied again
+	attempts := testutil.ToFloat64(configReloadsTotal)
+	require.NoError(t, c.SetDiscoveredDistributions(map[string]*Distribution{
+		"sandbox": {ID: "E1", Prefixes: []string{"/my/path"}},
+	}))
+	assert.Equal(t, attempts, testutil.ToFloat64(configReloadsTotal))
+
+	// the hash covers the discovered distributions
+	info := c.Info()
+	require.NoError(t, c.SetDiscoveredDistributions(map[string]*Distribution{
+		"sandbox": {ID: "E1", Prefixes: []string{"/my/path"}},
+		"docs":    {ID: "E2", Prefixes: []string{"/docs"}, Description: "Documentation"},
+	}))
+	assert.Equal(t, "Documentation", c.Distribution("docs").Description)
+	assert.Equal(t, attempts+1, testutil.ToFloat64(configReloadsTotal))
+	assert.NotEqual(t, info.Hash, c.Info().Hash)
+	assert.True(t, c.Info().LoadedAt.After(info.LoadedAt))
+
+	tests := []struct {
+		name       string
+		discovered map[string]*Distribution
+		err        string
+		docs       string
+	}{
+		{
+			name: "configured and discovered",
+			discovered: map[string]*Distribution{
+				"sandbox": {ID: "E1", Prefixes: []string{"/my/path"}},
+				"docs":    {ID: "E2", Prefixes: []string{"/docs"}, Description: "Docs"},
+				"team-a":  {ID: "E1", Prefixes: []string{"/team-a"}},
+			},
+			err:  "skipped discovered distributions: team-a: error parsing configuration: distribution team-a is configured and discovered on cloudfront distribution E1",
+			docs: "Docs",
+		},
+		{
+			name: "overlapping prefix",
+			discovered: map[string]*Distribution{
+				"sandbox": {ID: "123", Prefixes: []string{"/team-a/sandbox"}},
+				"docs":    {ID: "E2", Prefixes: []string{"/docs"}, Description: "Documentation"},
+			},
+			err:  "skipped discovered distributions: sandbox: error parsing configuration: distribution sandbox (discovered on cloudfront distribution 123) prefix: /team-a/sandbox overlaps distribution team-a prefix: /team-a on id: 123",
+			docs: "Documentation",
+		},
+		{
+			name: "removed while entitled",
+			discovered: map[string]*Distribution{
+				"docs": {ID: "E2", Prefixes: []string{"/docs"}, Description: "Documentation"},
+			},
+			err:  "skipped discovered distributions: sandbox: error parsing configuration: distribution sandbox in entitlement sandbox-team is not configured",
+			docs: "Documentation",
+		},
+	}
+
+	for _, test := range tests {
+		assert.EqualError(t, c.SetDiscoveredDistributions(test.discovered), test.err, test.name)
+
+		// skipped distributions keep their previous state, the others are applied
+		assert.Equal(t, &Distribution{ID: "E1", Prefixes: []string{"/my/path"}, Discovered: true}, c.Distribution("sandbox"), test.name)
+		assert.False(t, c.Distribution("team-a").Discovered, test.name)
+		assert.Equal(t, test.docs, c.Distribution("docs").Description, test.name)
+		assert.Empty(t, c.Info().LastReloadError, test.name)
+	}
+
+	// a reload of the static configuration keeps the discovered distributions
+	require.NoError(t, c.parse([]byte(teamAConfig+`  sandbox-team:
+    - sandbox
+  docs-team:
+    - docs
+`)))
+	assert.NotNil(t, c.Distribution("docs"))
+	assert.Contains(t, c.Snapshot().byClaim, "docs-team")
+
+	// discovered distributions cannot be marked in configuration files
+	assert.EqualError(t, c.parse([]byte(`distributions:
+  team-b:
+    id: "456"
+    prefix: /team-b
+    discovered: true
+`)), "error parsing configuration: unknown key distributions.team-b.discovered at line 5")
+}
+
+func TestSetDiscoveredDistributionsConcurrentLookups(t *testing.T) {
+	c := New()
+	require.NoError(t, c.parse([]byte(`distributions:
+  localized:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+      - regex: /v[0-9]+/team-a
+policies:
+  - name: small-batches
+    expression: size(paths) <= 2
+`)))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			// every discovery revalidates the published static configuration
+			discovered := map[string]*Distribution{"docs": {ID: "E2", Prefixes: []string{"/docs"}}}
+			if i%2 == 1 {
+				discovered["api"] = &Distribution{ID: "E2", Patterns: []PathPattern{{Glob: "/api/*"}}}
+			}
+			assert.NoError(t, c.SetDiscoveredDistributions(discovered))
+		}
+	}()
+
+	req := PolicyRequest{Identity: &core.Identity{}, DistributionName: "localized", Paths: []string{"/en/team-a"}, Now: time.Now()}
+	for i := 0; i < 1000; i++ {
+		snapshot := c.Snapshot()
+
+		distribution := snapshot.Distribution("localized")
+		assert.True(t, distribution.Covers("/en/team-a/index.html"))
+		assert.True(t, distribution.Covers("/v2/team-a"))
+
+		req.Distribution = distribution
+		policy, err := snapshot.EvaluatePolicies(req)
+		assert.NoError(t, err)
+		assert.Nil(t, policy)
+	}
+
+	close(done)
+	wg.Wait()
+}
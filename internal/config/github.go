@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+)
+
+// GitHubActionsIssuer is the issuer of GitHub Actions OIDC tokens on github.com
+const GitHubActionsIssuer = "https://token.actions.githubusercontent.com"
+
+// GitHubActionsRun selects GitHub Actions workflow runs whose token claims
+// match all of the conditions.  Conditions are exact values or path.Match
+// patterns e.g. refs/heads/release/*, an empty condition other than
+// repository matches any value.
+type GitHubActionsRun struct {
+	// Issuer of the tokens, defaults to GitHubActionsIssuer and is set for GitHub Enterprise Server
+	Issuer      string `json:"issuer,omitempty"`
+	Repository  string `json:"repository"`
+	Ref         string `json:"ref,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Workflow    string `json:"workflow,omitempty"`
+}
+
+// GitHubActionsBinding entitles the GitHub Actions workflow runs it selects
+type GitHubActionsBinding struct {
+	GitHubActionsRun
+
+	Entitlements []Grant `json:"entitlements"`
+}
+
+func (b *GitHubActionsRun) conditions() map[string]string {
+	return map[string]string{
+		"repository":  b.Repository,
+		"ref":         b.Ref,
+		"environment": b.Environment,
+		"workflow":    b.Workflow,
+	}
+}
+
+func (b *GitHubActionsRun) issuer() string {
+	if b.Issuer == "" {
+		return GitHubActionsIssuer
+	}
+
+	return b.Issuer
+}
+
+func (b *GitHubActionsRun) validate() error {
+	if b.Repository == "" {
+		return errors.New("repository must not be empty")
+	}
+
+	for claim, pattern := range b.conditions() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %v", claim, pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *GitHubActionsBinding) validate(distributions distributionsMap) error {
+	if err := b.GitHubActionsRun.validate(); err != nil {
+		return err
+	}
+
+	if len(b.Entitlements) == 0 {
+		return errors.New("entitlements must not be empty")
+	}
+
+	for _, grant := range b.Entitlements {
+		distribution, ok := distributions[grant.Distribution]
+		if !ok {
+			return fmt.Errorf("distribution %s is not configured", grant.Distribution)
+		}
+
+		if err := grant.validate(distribution); err != nil {
+			return fmt.Errorf("distribution %s: %v", grant.Distribution, err)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the identity is a workflow run of the issuer
+// satisfying every condition
+func (b *GitHubActionsRun) matches(identity *core.Identity) bool {
+	if identity.Issuer != b.issuer() || identity.Attributes == nil {
+		return false
+	}
+
+	for claim, pattern := range b.conditions() {
+		if pattern == "" {
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, identity.Attributes[claim]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validateGitHubActionsBindings(bindings []GitHubActionsBinding, distributions distributionsMap) error {
+	for i := range bindings {
+		if err := bindings[i].validate(distributions); err != nil {
+			return fmt.Errorf("error parsing configuration: github actions binding %d for %s: %v", i, bindings[i].Repository, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHubActionsBindingValidation(t *testing.T) {
+	tests := []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: `
+githubActions:
+  - ref: refs/heads/main
+    entitlements: [dis1]
+`,
+			err: "error parsing configuration: github actions binding 0 for : repository must not be empty",
+		},
+		{
+			yaml: `
+githubActions:
+  - repository: org/site
+    ref: "refs/heads/[main"
+    entitlements: [dis1]
+`,
+			err: `error parsing configuration: github actions binding 0 for org/site: invalid ref pattern "refs/heads/[main": syntax error in pattern`,
+		},
+		{
+			yaml: `
+githubActions:
+  - repository: org/site
+`,
+			err: "error parsing configuration: github actions binding 0 for org/site: entitlements must not be empty",
+		},
+		{
+			yaml: `
+githubActions:
+  - repository: org/site
+    entitlements: [dis3]
+`,
+			err: "error parsing configuration: github actions binding 0 for org/site: distribution dis3 is not configured",
+		},
+		{
+			yaml: `
+githubActions:
+  - repository: org/site
+    entitlements:
+      - distribution: dis1
+        paths: [/bar]
+`,
+			err: "error parsing configuration: github actions binding 0 for org/site: distribution dis1: path /bar is outside of prefix /foo",
+		},
+		{
+			yaml: `
+githubActions:
+  - repository: org/*
+    ref: refs/heads/release/*
+    environment: production
+    workflow: deploy
+    entitlements: [dis1]
+`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+` + test.yaml))
+		if test.err == "" {
+			assert.NoError(t, err, test.yaml)
+		} else {
+			assert.EqualError(t, err, test.err, test.yaml)
+		}
+	}
+}
+
+func TestGitHubActionsDistributions(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  prod-site:
+    id: "123"
+    prefix: "/site"
+  staging-site:
+    id: "456"
+    prefix: "/staging"
+githubActions:
+  - repository: org/site
+    ref: refs/heads/main
+    entitlements: [prod-site]
+  - repository: org/site
+    ref: refs/heads/release/*
+    environment: staging
+    workflow: deploy
+    entitlements:
+      - distribution: staging-site
+        paths: [/staging/docs]
+  - issuer: https://github.example.com/_services/token
+    repository: org/site
+    entitlements:
+      - distribution: staging-site
+        role: viewer
+`))
+	assert.NoError(t, err)
+
+	identity := func(issuer string, attributes map[string]string) *core.Identity {
+		return &core.Identity{Subject: "repo:org/site", Issuer: issuer, Attributes: attributes}
+	}
+
+	tests := []struct {
+		name     string
+		identity *core.Identity
+		want     []string
+	}{
+		{
+			name:     "main branch",
+			identity: identity(GitHubActionsIssuer, map[string]string{"repository": "org/site", "ref": "refs/heads/main"}),
+			want:     []string{"prod-site"},
+		},
+		{
+			name:     "other repository",
+			identity: identity(GitHubActionsIssuer, map[string]string{"repository": "org/other", "ref": "refs/heads/main"}),
+			want:     []string{},
+		},
+		{
+			name:     "feature branch",
+			identity: identity(GitHubActionsIssuer, map[string]string{"repository": "org/site", "ref": "refs/heads/feature"}),
+			want:     []string{},
+		},
+		{
+			name:     "release branch with environment and workflow",
+			identity: identity(GitHubActionsIssuer, map[string]string{"repository": "org/site", "ref": "refs/heads/release/1.0", "environment": "staging", "workflow": "deploy"}),
+			want:     []string{"staging-site"},
+		},
+		{
+			name:     "release branch without environment",
+			identity: identity(GitHubActionsIssuer, map[string]string{"repository": "org/site", "ref": "refs/heads/release/1.0", "workflow": "deploy"}),
+			want:     []string{},
+		},
+		{
+			name:     "enterprise issuer",
+			identity: identity("https://github.example.com/_services/token", map[string]string{"repository": "org/site", "ref": "refs/heads/main"}),
+			want:     []string{"staging-site"},
+		},
+		{
+			name:     "attributes without issuer",
+			identity: &core.Identity{Subject: "jdoe", Attributes: map[string]string{"repository": "org/site", "ref": "refs/heads/main"}},
+			want:     []string{},
+		},
+	}
+
+	for _, test := range tests {
+		lookup := c.DistributionsFromClaims(test.identity)
+		assert.ElementsMatch(t, test.want, keys(lookup), test.name)
+	}
+
+	lookup := c.DistributionsFromClaims(identity(GitHubActionsIssuer, map[string]string{"repository": "org/site", "ref": "refs/heads/release/2", "environment": "staging", "workflow": "deploy"}))
+	assert.Equal(t, []string{"/staging/docs"}, lookup["staging-site"].GrantedPaths())
+}
+
+func TestGitHubActionsPolicy(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+policies:
+  - name: github-push
+    distributions: [dis1]
+    expression: identity.issuer == "" || identity.attributes.event_name == "push"
+`))
+	assert.NoError(t, err)
+
+	req := PolicyRequest{
+		Identity:         &core.Identity{Issuer: GitHubActionsIssuer, Attributes: map[string]string{"event_name": "pull_request"}},
+		DistributionName: "dis1",
+		Distribution:     c.Distribution("dis1"),
+		Paths:            []string{"/foo/a"},
+	}
+
+	policy, err := c.EvaluatePolicies(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "github-push", policy.Name)
+
+	req.Identity.Attributes["event_name"] = "push"
+	policy, err = c.EvaluatePolicies(req)
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	req.Identity = &core.Identity{Subject: "jdoe"}
+	policy, err = c.EvaluatePolicies(req)
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+}
@@ -0,0 +1,432 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrGrantNotFound = errors.New("grant not found")
+	ErrNoGrantStore  = errors.New("temporary grants are not enabled")
+	ErrInvalidGrant  = errors.New("invalid grant")
+)
+
+// Types of grant holders
+const (
+	HolderClaim             = "claim"
+	HolderSubject           = "sub"
+	HolderEmail             = "email"
+	HolderPreferredUsername = "preferred_username"
+	HolderServiceAccount    = "serviceAccount"
+)
+
+const grantIDBytes = 8
+
+var validHolderTypes = []string{HolderClaim, HolderSubject, HolderEmail, HolderPreferredUsername, HolderServiceAccount}
+
+// GrantHolder identifies who a temporary grant is issued to, by a claim or
+// by one of the user attributes used for user entitlements
+type GrantHolder struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+func (h GrantHolder) validate() error {
+	if !contains(validHolderTypes, h.Type) {
+		return fmt.Errorf("holder type %q must be one of %v", h.Type, validHolderTypes)
+	}
+
+	if h.Name == "" {
+		return errors.New("holder name must not be empty")
+	}
+
+	return nil
+}
+
+func (h GrantHolder) matches(identity *core.Identity) bool {
+	switch h.Type {
+	case HolderClaim:
+		return contains(identity.Claims, h.Name)
+	case HolderSubject:
+		return identity.Subject != "" && identity.Subject == h.Name
+	case HolderEmail:
+		return identity.Email != "" && strings.EqualFold(identity.Email, h.Name)
+	case HolderPreferredUsername:
+		return identity.PreferredUsername != "" && identity.PreferredUsername == h.Name
+	case HolderServiceAccount:
+		return identity.ServiceAccount != "" && identity.ServiceAccount == h.Name
+	}
+
+	return false
+}
+
+func (h GrantHolder) String() string {
+	return h.Type + ":" + h.Name
+}
+
+// TemporaryGrant is a grant issued at runtime through the API, e.g. break
+// glass access during an incident.  Temporary grants always expire.
+type TemporaryGrant struct {
+	ID           string      `json:"id"`
+	Holder       GrantHolder `json:"holder"`
+	Distribution string      `json:"distribution"`
+	Role         Role        `json:"role"`
+	Paths        []string    `json:"paths,omitempty"`
+	NotBefore    *time.Time  `json:"notBefore,omitempty"`
+	Expires      time.Time   `json:"expires"`
+	CreatedBy    string      `json:"createdBy"`
+	Created      time.Time   `json:"created"`
+}
+
+func (t TemporaryGrant) grant() Grant {
+	expires := t.Expires
+	return Grant{
+		Distribution: t.Distribution,
+		Role:         t.Role,
+		Paths:        t.Paths,
+		NotBefore:    t.NotBefore,
+		Expires:      &expires,
+	}
+}
+
+// grantSet is an immutable set of temporary grants indexed by the
+// distribution they are issued on
+type grantSet struct {
+	grants         []TemporaryGrant
+	byDistribution map[string][]TemporaryGrant
+}
+
+func newGrantSet(grants []TemporaryGrant) *grantSet {
+	set := &grantSet{grants: grants, byDistribution: make(map[string][]TemporaryGrant)}
+	for _, grant := range grants {
+		set.byDistribution[grant.Distribution] = append(set.byDistribution[grant.Distribution], grant)
+	}
+
+	return set
+}
+
+// GrantStore persists temporary grants in a JSON file.  The stored grants
+// are published as an immutable set, which a change swaps atomically, so
+// lookups take no lock.
+type GrantStore struct {
+	// mu serializes changes to the file and the set
+	mu     sync.Mutex
+	file   string
+	grants atomic.Pointer[grantSet]
+}
+
+// NewGrantStore returns a store persisted to file, loading the grants
+// already stored in it
+func NewGrantStore(file string) (*GrantStore, error) {
+	s := &GrantStore{file: file}
+	s.grants.Store(newGrantSet([]TemporaryGrant{}))
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		grants := []TemporaryGrant{}
+		if err := json.Unmarshal(data, &grants); err != nil {
+			return nil, fmt.Errorf("error loading grants file %s: %w", file, err)
+		}
+		s.grants.Store(newGrantSet(grants))
+	}
+
+	return s, nil
+}
+
+// List returns all stored grants
+func (s *GrantStore) List() []TemporaryGrant {
+	return append([]TemporaryGrant{}, s.grants.Load().grants...)
+}
+
+// Len returns the number of stored grants
+func (s *GrantStore) Len() int {
+	return len(s.grants.Load().grants)
+}
+
+// Add stores the grant under a new ID
+func (s *GrantStore) Add(grant TemporaryGrant) (TemporaryGrant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := make([]byte, grantIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return TemporaryGrant{}, err
+	}
+	grant.ID = hex.EncodeToString(id)
+
+	grants := append(append([]TemporaryGrant{}, s.grants.Load().grants...), grant)
+	if err := s.save(grants); err != nil {
+		return TemporaryGrant{}, err
+	}
+
+	return grant, nil
+}
+
+// Delete removes the grant with id
+func (s *GrantStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.grants.Load().grants
+	grants := []TemporaryGrant{}
+	for _, grant := range current {
+		if grant.ID != id {
+			grants = append(grants, grant)
+		}
+	}
+
+	if len(grants) == len(current) {
+		return ErrGrantNotFound
+	}
+
+	return s.save(grants)
+}
+
+// removeExpired removes and returns the grants expired at now
+func (s *GrantStore) removeExpired(now time.Time) ([]TemporaryGrant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grants := []TemporaryGrant{}
+	expired := []TemporaryGrant{}
+	for _, grant := range s.grants.Load().grants {
+		if grant.grant().expiredAt(now) {
+			expired = append(expired, grant)
+		} else {
+			grants = append(grants, grant)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	return expired, s.save(grants)
+}
+
+// save atomically replaces the grants file, the published set is only
+// swapped once the grants are persisted
+func (s *GrantStore) save(grants []TemporaryGrant) error {
+	data, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), s.file); err != nil {
+		return err
+	}
+
+	s.grants.Store(newGrantSet(grants))
+	return nil
+}
+
+// TemporaryGrants returns the temporary grants issued on the distribution
+func (c *Config) TemporaryGrants(distributionName string) ([]TemporaryGrant, error) {
+	if c.store == nil {
+		return nil, ErrNoGrantStore
+	}
+
+	return append([]TemporaryGrant{}, c.store.grants.Load().byDistribution[distributionName]...), nil
+}
+
+// AddTemporaryGrant validates the grant against the configured distribution
+// and stores it
+func (c *Config) AddTemporaryGrant(grant TemporaryGrant) (TemporaryGrant, error) {
+	if c.store == nil {
+		return TemporaryGrant{}, ErrNoGrantStore
+	}
+
+	if grant.Role == "" {
+		grant.Role = DefaultRole
+	}
+	if grant.Holder.Type == HolderEmail {
+		grant.Holder.Name = strings.ToLower(grant.Holder.Name)
+	}
+
+	if err := grant.Holder.validate(); err != nil {
+		return TemporaryGrant{}, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+	}
+
+	now := time.Now()
+	if grant.Expires.IsZero() || !grant.Expires.After(now) {
+		return TemporaryGrant{}, fmt.Errorf("%w: expires must be in the future", ErrInvalidGrant)
+	}
+
+	if c.maxGrantDuration > 0 {
+		start := now
+		if grant.NotBefore != nil && grant.NotBefore.After(now) {
+			start = *grant.NotBefore
+		}
+		if grant.Expires.Sub(start) > c.maxGrantDuration {
+			return TemporaryGrant{}, fmt.Errorf("%w: expires must be within %s of notBefore", ErrInvalidGrant, c.maxGrantDuration)
+		}
+	}
+
+	distribution := c.Distribution(grant.Distribution)
+	if distribution == nil {
+		return TemporaryGrant{}, fmt.Errorf("%w: distribution %s is not configured", ErrInvalidGrant, grant.Distribution)
+	}
+
+	if err := grant.grant().validate(distribution); err != nil {
+		return TemporaryGrant{}, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+	}
+
+	grant.Created = now.UTC()
+
+	stored, err := c.store.Add(grant)
+	if err != nil {
+		return TemporaryGrant{}, err
+	}
+
+	temporaryGrants.Set(float64(c.store.Len()))
+
+	log.WithFields(log.Fields{
+		"id":           stored.ID,
+		"holder":       stored.Holder.String(),
+		"distribution": stored.Distribution,
+		"role":         stored.Role,
+		"expires":      stored.Expires,
+		"createdBy":    stored.CreatedBy,
+	}).Info("temporary grant created")
+
+	return stored, nil
+}
+
+// DeleteTemporaryGrant revokes the temporary grant with id on the distribution
+func (c *Config) DeleteTemporaryGrant(distributionName string, id string) error {
+	grants, err := c.TemporaryGrants(distributionName)
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range grants {
+		if grant.ID == id {
+			if err := c.store.Delete(id); err != nil {
+				return err
+			}
+
+			temporaryGrants.Set(float64(c.store.Len()))
+			log.WithFields(log.Fields{"id": id, "distribution": distributionName}).Info("temporary grant deleted")
+
+			return nil
+		}
+	}
+
+	return ErrGrantNotFound
+}
+
+// ExpireGrants removes expired temporary grants from the store and reports
+// configured grants that expired since they were last checked
+func (c *Config) ExpireGrants() {
+	now := time.Now()
+
+	if c.store != nil {
+		expired, err := c.store.removeExpired(now)
+		if err != nil {
+			log.WithError(err).Error("error removing expired temporary grants")
+		}
+
+		for _, grant := range expired {
+			grantsExpiredTotal.WithLabelValues(grantSourceStore).Inc()
+			log.WithFields(log.Fields{
+				"id":           grant.ID,
+				"holder":       grant.Holder.String(),
+				"distribution": grant.Distribution,
+				"expires":      grant.Expires,
+			}).Info("temporary grant expired")
+		}
+
+		temporaryGrants.Set(float64(c.store.Len()))
+	}
+
+	snapshot := c.Snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reportedExpired == nil {
+		c.reportedExpired = make(map[string]struct{})
+	}
+
+	report := func(holder GrantHolder, grants []Grant) {
+		for _, grant := range grants {
+			if !grant.expiredAt(now) {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s", holder, grant.Distribution, grant.Expires.Format(time.RFC3339))
+			if _, ok := c.reportedExpired[key]; ok {
+				continue
+			}
+			c.reportedExpired[key] = struct{}{}
+
+			grantsExpiredTotal.WithLabelValues(grantSourceConfig).Inc()
+			log.WithFields(log.Fields{
+				"holder":       holder.String(),
+				"distribution": grant.Distribution,
+				"expires":      grant.Expires,
+			}).Warn("configured grant expired, remove it from the configuration")
+		}
+	}
+
+	for claim, grants := range snapshot.entitlements {
+		report(GrantHolder{Type: HolderClaim, Name: claim}, grants)
+	}
+	for name, grants := range snapshot.users.Subject {
+		report(GrantHolder{Type: HolderSubject, Name: name}, grants)
+	}
+	for name, grants := range snapshot.users.Email {
+		report(GrantHolder{Type: HolderEmail, Name: name}, grants)
+	}
+	for name, grants := range snapshot.users.PreferredUsername {
+		report(GrantHolder{Type: HolderPreferredUsername, Name: name}, grants)
+	}
+	for name, account := range snapshot.serviceAccounts {
+		report(GrantHolder{Type: HolderServiceAccount, Name: name}, account.Entitlements)
+	}
+}
+
+// WatchGrantExpiry runs ExpireGrants every interval
+func (c *Config) WatchGrantExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			c.ExpireGrants()
+		}
+	}()
+}
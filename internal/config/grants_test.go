@@ -0,0 +1,213 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const grantsTestYaml = `
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+  dis2:
+    id: "456"
+    prefix: "/bar"
+entitlements:
+  oncall:
+    - distribution: dis1
+      notBefore: "2000-01-01T00:00:00Z"
+      expires: "2100-01-01T00:00:00Z"
+    - distribution: dis2
+      expires: "2000-01-01T00:00:00Z"
+  future:
+    - distribution: dis1
+      notBefore: "2100-01-01T00:00:00Z"
+`
+
+func TestGrantTimeWindow(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(grantsTestYaml))
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"dis1"}, keys(c.DistributionsFromClaims(&core.Identity{Claims: []string{"oncall"}})))
+	assert.Empty(t, c.DistributionsFromClaims(&core.Identity{Claims: []string{"future"}}))
+
+	_, err = NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+entitlements:
+  oncall:
+    - distribution: dis1
+      notBefore: "2100-01-01T00:00:00Z"
+      expires: "2000-01-01T00:00:00Z"
+`))
+	assert.EqualError(t, err, "error parsing configuration: distribution dis1 in entitlement oncall: expires 2000-01-01T00:00:00Z must be after notBefore 2100-01-01T00:00:00Z")
+}
+
+func TestGrantStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "grants.json")
+
+	store, err := NewGrantStore(file)
+	require.NoError(t, err)
+	assert.Empty(t, store.List())
+
+	expires := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	g1, err := store.Add(TemporaryGrant{Holder: GrantHolder{Type: HolderClaim, Name: "oncall"}, Distribution: "dis1", Role: RoleInvalidator, Expires: expires})
+	require.NoError(t, err)
+	assert.NotEmpty(t, g1.ID)
+
+	g2, err := store.Add(TemporaryGrant{Holder: GrantHolder{Type: HolderEmail, Name: "jdoe@example.com"}, Distribution: "dis2", Role: RoleViewer, Expires: expires})
+	require.NoError(t, err)
+	assert.NotEqual(t, g1.ID, g2.ID)
+
+	// grants survive a restart
+	reloaded, err := NewGrantStore(file)
+	require.NoError(t, err)
+	assert.Equal(t, []TemporaryGrant{g1, g2}, reloaded.List())
+	assert.Equal(t, map[string][]TemporaryGrant{"dis1": {g1}, "dis2": {g2}}, reloaded.grants.Load().byDistribution)
+
+	// the published set is swapped, not modified
+	published := reloaded.grants.Load()
+	assert.NoError(t, reloaded.Delete(g1.ID))
+	assert.ErrorIs(t, reloaded.Delete(g1.ID), ErrGrantNotFound)
+	assert.Equal(t, map[string][]TemporaryGrant{"dis2": {g2}}, reloaded.grants.Load().byDistribution)
+	assert.Equal(t, []TemporaryGrant{g1, g2}, published.grants)
+	assert.Equal(t, 1, reloaded.Len())
+
+	reloaded, err = NewGrantStore(file)
+	require.NoError(t, err)
+	assert.Equal(t, []TemporaryGrant{g2}, reloaded.List())
+}
+
+func TestAddTemporaryGrant(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(grantsTestYaml))
+	require.NoError(t, err)
+
+	_, err = c.AddTemporaryGrant(TemporaryGrant{})
+	assert.ErrorIs(t, err, ErrNoGrantStore)
+
+	store, err := NewGrantStore(filepath.Join(t.TempDir(), "grants.json"))
+	require.NoError(t, err)
+
+	c, err = NewTestConfigWithYaml([]byte(grantsTestYaml), WithGrantStore(store), WithMaxGrantDuration(24*time.Hour))
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	holder := GrantHolder{Type: HolderEmail, Name: "JDoe@example.com"}
+
+	tests := []struct {
+		name  string
+		grant TemporaryGrant
+		err   string
+	}{
+		{
+			name:  "unknown holder type",
+			grant: TemporaryGrant{Holder: GrantHolder{Type: "group", Name: "g1"}, Distribution: "dis1", Expires: expires},
+			err:   `invalid grant: holder type "group" must be one of [claim sub email preferred_username serviceAccount]`,
+		},
+		{
+			name:  "missing expiry",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1"},
+			err:   "invalid grant: expires must be in the future",
+		},
+		{
+			name:  "expired",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1", Expires: time.Now().Add(-time.Hour)},
+			err:   "invalid grant: expires must be in the future",
+		},
+		{
+			name:  "unknown distribution",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis3", Expires: expires},
+			err:   "invalid grant: distribution dis3 is not configured",
+		},
+		{
+			name:  "path outside of distribution",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1", Paths: []string{"/bar"}, Expires: expires},
+			err:   "invalid grant: path /bar is outside of prefix /foo",
+		},
+		{
+			name:  "exceeds maximum duration",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1", Expires: time.Now().Add(48 * time.Hour)},
+			err:   "invalid grant: expires must be within 24h0m0s of notBefore",
+		},
+		{
+			name:  "exceeds maximum duration after notBefore",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1", NotBefore: &expires, Expires: expires.Add(25 * time.Hour)},
+			err:   "invalid grant: expires must be within 24h0m0s of notBefore",
+		},
+		{
+			name:  "unknown role",
+			grant: TemporaryGrant{Holder: holder, Distribution: "dis1", Role: "owner", Expires: expires},
+			err:   `invalid grant: unknown role "owner"`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := c.AddTemporaryGrant(test.grant)
+		assert.EqualError(t, err, test.err, test.name)
+		assert.ErrorIs(t, err, ErrInvalidGrant, test.name)
+	}
+
+	identity := &core.Identity{Email: "jdoe@EXAMPLE.com"}
+	assert.Empty(t, c.DistributionsFromClaims(identity))
+
+	grant, err := c.AddTemporaryGrant(TemporaryGrant{Holder: holder, Distribution: "dis2", Expires: expires, CreatedBy: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, RoleInvalidator, grant.Role)
+	assert.Equal(t, "jdoe@example.com", grant.Holder.Name)
+
+	assert.Equal(t, map[string]Grants{"dis2": {grant.grant()}}, c.DistributionsFromClaims(identity))
+
+	grants, err := c.TemporaryGrants("dis2")
+	require.NoError(t, err)
+	assert.Equal(t, []TemporaryGrant{grant}, grants)
+
+	// grants are deleted within their distribution only
+	assert.ErrorIs(t, c.DeleteTemporaryGrant("dis1", grant.ID), ErrGrantNotFound)
+	assert.NoError(t, c.DeleteTemporaryGrant("dis2", grant.ID))
+	assert.Empty(t, c.DistributionsFromClaims(identity))
+}
+
+func TestExpireGrants(t *testing.T) {
+	store, err := NewGrantStore(filepath.Join(t.TempDir(), "grants.json"))
+	require.NoError(t, err)
+
+	c, err := NewTestConfigWithYaml([]byte(grantsTestYaml), WithGrantStore(store))
+	require.NoError(t, err)
+
+	expired, err := store.Add(TemporaryGrant{Holder: GrantHolder{Type: HolderClaim, Name: "oncall"}, Distribution: "dis1", Role: RoleAdmin, Expires: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	active, err := store.Add(TemporaryGrant{Holder: GrantHolder{Type: HolderClaim, Name: "oncall"}, Distribution: "dis2", Role: RoleAdmin, Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	storeExpired := testutil.ToFloat64(grantsExpiredTotal.WithLabelValues(grantSourceStore))
+	configExpired := testutil.ToFloat64(grantsExpiredTotal.WithLabelValues(grantSourceConfig))
+
+	c.ExpireGrants()
+
+	assert.Equal(t, []TemporaryGrant{active}, store.List())
+	assert.NotContains(t, store.List(), expired)
+	assert.Equal(t, storeExpired+1, testutil.ToFloat64(grantsExpiredTotal.WithLabelValues(grantSourceStore)))
+	assert.Equal(t, configExpired+1, testutil.ToFloat64(grantsExpiredTotal.WithLabelValues(grantSourceConfig)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(temporaryGrants))
+
+	// expired configured grants are reported once
+	c.ExpireGrants()
+	assert.Equal(t, configExpired+1, testutil.ToFloat64(grantsExpiredTotal.WithLabelValues(grantSourceConfig)))
+}
+
+func keys(m map[string]Grants) []string {
+	ret := []string{}
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// LintWarning is a finding about configuration that loads but is likely a mistake
+type LintWarning struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (w LintWarning) String() string {
+	switch {
+	case w.File != "" && w.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", w.File, w.Line, w.Message)
+	case w.File != "":
+		return fmt.Sprintf("%s: %s", w.File, w.Message)
+	default:
+		return w.Message
+	}
+}
+
+// LintReport is the result of validating a configuration offline
+type LintReport struct {
+	Valid    bool          `json:"valid"`
+	Errors   []string      `json:"errors,omitempty"`
+	Warnings []LintWarning `json:"warnings,omitempty"`
+}
+
+// position is the location of a key in a configuration file
+type position struct {
+	file string
+	line int
+}
+
+// lintSources locates the keys of the linted files by section
+type lintSources map[string]map[string]position
+
+func (s lintSources) warning(section, key, message string) LintWarning {
+	p := s[section][key]
+	return LintWarning{File: p.file, Line: p.line, Message: message}
+}
+
+// Lint validates the configuration file, or the directory of files, the way
+// the server loads it and reports warnings about suspicious configuration
+func Lint(path string) *LintReport {
+	report := &LintReport{}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = configFiles(path); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+	}
+
+	sources := lintSources{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+
+		unknown, err := lintFile(file, data, sources)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("error parsing configuration file %s: %v", file, err))
+			return report
+		}
+		report.Errors = append(report.Errors, unknown...)
+	}
+
+	// unknown keys are rejected when loading, all of them are reported
+	if len(report.Errors) > 0 {
+		return report
+	}
+
+	c := New()
+	if info.IsDir() {
+		err = c.loadDir(path)
+	} else {
+		err = c.load(path)
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	report.Valid = true
+	report.Warnings = append(report.Warnings, c.lint(sources)...)
+
+	return report
+}
+
+// lintFile reports every unknown key of a file and records the location of
+// its distributions and entitlements
+func lintFile(file string, data []byte, sources lintSources) ([]string, error) {
+	root := yamlv3.Node{}
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	for _, section := range [][]string{
+		{"distributions"},
+		{"entitlements"},
+		{"serviceAccounts"},
+		{"users", "sub"},
+		{"users", "email"},
+		{"users", "preferred_username"},
+	} {
+		lines, err := keyLines(data, section...)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.Join(section, ".")
+		if sources[name] == nil {
+			sources[name] = make(map[string]position)
+		}
+		for key, line := range lines {
+			sources[name][key] = position{file: file, line: line}
+		}
+	}
+
+	unknown := []string{}
+	if len(root.Content) > 0 {
+		unknownKeys(root.Content[0], reflect.TypeOf(document{}), "", func(node *yamlv3.Node, path string) {
+			unknown = append(unknown, LintWarning{File: file, Line: node.Line, Message: fmt.Sprintf("unknown key %s", path)}.String())
+		})
+	}
+
+	return unknown, nil
+}
+
+// checkUnknownKeys returns an error naming the first key of the YAML
+// document without a matching configuration field
+func checkUnknownKeys(data []byte) error {
+	root := yamlv3.Node{}
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		// syntax errors are reported by the decoder
+		return nil
+	}
+
+	var err error
+	if len(root.Content) > 0 {
+		unknownKeys(root.Content[0], reflect.TypeOf(document{}), "", func(node *yamlv3.Node, path string) {
+			if err == nil {
+				err = fmt.Errorf("unknown key %s at line %d", path, node.Line)
+			}
+		})
+	}
+
+	return err
+}
+
+// jsonFields returns the fields of a struct type by their JSON names,
+// including the fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, ft := range jsonFields(field.Type) {
+				fields[embedded] = ft
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// unknownKeys walks a YAML node along the Go type it is decoded into and
+// reports mapping keys without a matching field
+func unknownKeys(node *yamlv3.Node, t reflect.Type, path string, report func(*yamlv3.Node, string)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yamlv3.MappingNode:
+		fields := jsonFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := strings.TrimPrefix(path+"."+key.Value, ".")
+
+			ft, ok := fields[key.Value]
+			if !ok {
+				report(key, keyPath)
+				continue
+			}
+			unknownKeys(node.Content[i+1], ft, keyPath, report)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			unknownKeys(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value, report)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	}
+}
+
+// lint reports distributions nobody is entitled to, entitlements without
+// distributions and suspicious prefixes of the loaded configuration
+func (c *Config) lint(sources lintSources) []LintWarning {
+	s := c.Snapshot()
+	warnings := []LintWarning{}
+	granted := make(map[string]struct{})
+
+	grantsOf := func(section, name, description string, grants []Grant) {
+		if len(grants) == 0 {
+			warnings = append(warnings, sources.warning(section, name, fmt.Sprintf("%s %s grants no distributions", description, name)))
+		}
+
+		for _, grant := range grants {
+			granted[grant.Distribution] = struct{}{}
+		}
+	}
+
+	for _, name := range sortedKeys(s.entitlements) {
+		grantsOf("entitlements", name, "entitlement", s.entitlements[name])
+	}
+
+	for _, users := range []struct {
+		section      string
+		description  string
+		entitlements entitlementsMap
+	}{
+		{section: "users.sub", description: "user entitlement sub", entitlements: s.users.Subject},
+		{section: "users.email", description: "user entitlement email", entitlements: s.users.Email},
+		{section: "users.preferred_username", description: "user entitlement preferred_username", entitlements: s.users.PreferredUsername},
+	} {
+		for _, name := range sortedKeys(users.entitlements) {
+			grantsOf(users.section, name, users.description, users.entitlements[name])
+		}
+	}
+
+	accounts := make([]string, 0, len(s.serviceAccounts))
+	for name := range s.serviceAccounts {
+		accounts = append(accounts, name)
+	}
+	sort.Strings(accounts)
+	for _, name := range accounts {
+		grantsOf("serviceAccounts", name, "service account", s.serviceAccounts[name].Entitlements)
+	}
+
+	for _, binding := range s.githubActions {
+		for _, grant := range binding.Entitlements {
+			granted[grant.Distribution] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(s.distributions))
+	for name := range s.distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := granted[name]; !ok {
+			warnings = append(warnings, sources.warning("distributions", name, fmt.Sprintf("distribution %s is not granted by any entitlement", name)))
+		}
+
+		for _, prefix := range s.distributions[name].PathPrefixes() {
+			switch {
+			case prefix == "" || prefix == "/":
+				warnings = append(warnings, sources.warning("distributions", name, fmt.Sprintf("distribution %s prefix %q covers the whole CloudFront distribution", name, prefix)))
+			case !strings.HasPrefix(prefix, "/"):
+				warnings = append(warnings, sources.warning("distributions", name, fmt.Sprintf("distribution %s prefix %s does not start with /, no invalidation path lies within it", name, prefix)))
+			}
+		}
+	}
+
+	return warnings
+}
+
+func sortedKeys(m entitlementsMap) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
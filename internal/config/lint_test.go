@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+  dis2:
+    id: "456"
+    prefix: /
+  unused:
+    id: "789"
+    prefix: docs
+entitlements:
+  grp1:
+    - dis1
+    - distribution: dis2
+      role: viewer
+  empty: []
+users:
+  sub:
+    jdoe: []
+`), 0o600))
+
+	report := Lint(file)
+	assert.Equal(t, &LintReport{
+		Valid: true,
+		Warnings: []LintWarning{
+			{File: file, Line: 16, Message: "entitlement empty grants no distributions"},
+			{File: file, Line: 19, Message: "user entitlement sub jdoe grants no distributions"},
+			{File: file, Line: 5, Message: `distribution dis2 prefix "/" covers the whole CloudFront distribution`},
+			{File: file, Line: 8, Message: "distribution unused is not granted by any entitlement"},
+			{File: file, Line: 8, Message: "distribution unused prefix docs does not start with /, no invalidation path lies within it"},
+		},
+	}, report)
+
+	require.NoError(t, os.WriteFile(file, []byte(`distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+  dis2:
+    id: "123"
+    prefix: /foo/bar
+`), 0o600))
+
+	report = Lint(file)
+	assert.False(t, report.Valid)
+	assert.Equal(t, []string{"error parsing configuration: distribution dis2 prefix: /foo/bar overlaps distribution dis1 prefix: /foo on id: 123"}, report.Errors)
+	assert.Empty(t, report.Warnings)
+
+	require.NoError(t, os.WriteFile(file, []byte(`distributions:
+  dis1:
+    id: "123"
+    prefx: /foo
+entitlements:
+  grp1:
+    - distribution: dis1
+      pths: [/x]
+polices: []
+`), 0o600))
+
+	report = Lint(file)
+	assert.False(t, report.Valid)
+	assert.Equal(t, []string{
+		file + ":4: unknown key distributions.dis1.prefx",
+		file + ":8: unknown key entitlements.grp1[0].pths",
+		file + ":9: unknown key polices",
+	}, report.Errors)
+
+	report = Lint(filepath.Join(dir, "missing.yaml"))
+	assert.False(t, report.Valid)
+	assert.Len(t, report.Errors, 1)
+}
+
+func TestLintDir(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"team-a.yaml": teamAConfig,
+		"team-b.yaml": `distributions:
+  team-b:
+    id: "123"
+    prefix: /team-b
+`,
+	})
+
+	report := Lint(dir)
+	assert.Equal(t, &LintReport{
+		Valid: true,
+		Warnings: []LintWarning{
+			{File: filepath.Join(dir, "team-b.yaml"), Line: 2, Message: "distribution team-b is not granted by any entitlement"},
+		},
+	}, report)
+}
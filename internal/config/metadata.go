@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+var (
+	// slackChannel is a Slack channel name as it is typed in Slack
+	slackChannel = regexp.MustCompile(`^#[a-z0-9][a-z0-9._-]{0,79}$`)
+	// labelKey is a label key like those of Kubernetes, optionally prefixed
+	labelKey = regexp.MustCompile(`^([a-z0-9]([a-z0-9.-]*[a-z0-9])?/)?[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// maxDescriptionLength keeps descriptions short enough for the UI
+const maxDescriptionLength = 1024
+
+// validateMetadata checks the descriptive fields of the distribution, which
+// are shown to users but never used to authorize requests
+func (d *Distribution) validateMetadata() error {
+	if len(d.Description) > maxDescriptionLength {
+		return fmt.Errorf("description must not exceed %d characters", maxDescriptionLength)
+	}
+
+	owners := make(map[string]struct{}, len(d.Owners))
+	for _, owner := range d.Owners {
+		if owner == "" {
+			return errors.New("owners must not be empty")
+		}
+
+		if _, ok := owners[owner]; ok {
+			return fmt.Errorf("owner %s is duplicated", owner)
+		}
+		owners[owner] = struct{}{}
+	}
+
+	if d.Contact != "" && !validContact(d.Contact) {
+		return fmt.Errorf("contact %s must be a Slack channel, e.g. #team-a, or an email address", d.Contact)
+	}
+
+	for _, name := range sortedStringKeys(d.Links) {
+		if name == "" {
+			return errors.New("link name must not be empty")
+		}
+
+		u, err := url.Parse(d.Links[name])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("link %s must be an absolute http(s) URL", name)
+		}
+	}
+
+	for _, key := range sortedStringKeys(d.Labels) {
+		if !labelKey.MatchString(key) {
+			return fmt.Errorf("label key %q must be alphanumeric with -, _ or . and an optional DNS prefix", key)
+		}
+	}
+
+	return nil
+}
+
+// validContact reports whether contact is a Slack channel or email address
+func validContact(contact string) bool {
+	if slackChannel.MatchString(contact) {
+		return true
+	}
+
+	address, err := mail.ParseAddress(contact)
+	return err == nil && address.Address == contact
+}
+
+// sortedStringKeys returns the keys of m in order, so validation reports the same
+// error on every load
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// copyStrings returns a copy of m, nil when m is empty
+func copyStrings(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+
+	return c
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		distribution Distribution
+		err          string
+	}{
+		{distribution: Distribution{}},
+		{
+			distribution: Distribution{
+				Description: "Documentation site of team a",
+				Owners:      []string{"team-a", "jane.doe"},
+				Contact:     "#team-a-support",
+				Links:       map[string]string{"runbook": "https://wiki.example.com/team-a/runbook", "site": "http://docs.example.com/team-a"},
+				Labels:      map[string]string{"tier": "1", "example.com/cost-center": "", "Team_A.env": "prod"},
+			},
+		},
+		{distribution: Distribution{Contact: "team-a@example.com"}},
+		{distribution: Distribution{Owners: []string{"team-a", ""}}, err: "owners must not be empty"},
+		{distribution: Distribution{Owners: []string{"team-a", "team-a"}}, err: "owner team-a is duplicated"},
+		{distribution: Distribution{Contact: "team-a"}, err: "contact team-a must be a Slack channel, e.g. #team-a, or an email address"},
+		{distribution: Distribution{Contact: "#Team A"}, err: "contact #Team A must be a Slack channel, e.g. #team-a, or an email address"},
+		{distribution: Distribution{Contact: "Team A <team-a@example.com>"}, err: "contact Team A <team-a@example.com> must be a Slack channel, e.g. #team-a, or an email address"},
+		{distribution: Distribution{Links: map[string]string{"": "https://example.com"}}, err: "link name must not be empty"},
+		{distribution: Distribution{Links: map[string]string{"runbook": "/team-a/runbook"}}, err: "link runbook must be an absolute http(s) URL"},
+		{distribution: Distribution{Links: map[string]string{"runbook": "javascript:alert(1)"}}, err: "link runbook must be an absolute http(s) URL"},
+		{distribution: Distribution{Labels: map[string]string{"-tier": "1"}}, err: `label key "-tier" must be alphanumeric with -, _ or . and an optional DNS prefix`},
+		{distribution: Distribution{Labels: map[string]string{"": "1"}}, err: `label key "" must be alphanumeric with -, _ or . and an optional DNS prefix`},
+	}
+
+	for _, test := range tests {
+		err := test.distribution.validateMetadata()
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	c := New()
+	require.NoError(t, c.parse([]byte(`distributions:
+  team-a:
+    id: "123"
+    prefix: /team-a
+    description: Documentation site of team a
+    owners:
+      - team-a
+    contact: "#team-a"
+    links:
+      runbook: https://wiki.example.com/team-a/runbook
+    labels:
+      tier: "1"
+`)))
+
+	want := &Distribution{
+		ID:          "123",
+		Prefix:      "/team-a",
+		Description: "Documentation site of team a",
+		Owners:      []string{"team-a"},
+		Contact:     "#team-a",
+		Links:       map[string]string{"runbook": "https://wiki.example.com/team-a/runbook"},
+		Labels:      map[string]string{"tier": "1"},
+	}
+	assert.Equal(t, want, c.Distribution("team-a"))
+
+	// lookups return copies of the active configuration
+	c.Distribution("team-a").Labels["tier"] = "2"
+	assert.Equal(t, want, c.Distribution("team-a"))
+
+	err := c.parse([]byte(`distributions:
+  team-a:
+    id: "123"
+    prefix: /team-a
+    contact: team-a
+`))
+	assert.EqualError(t, err, "error parsing configuration: distribution team-a contact team-a must be a Slack channel, e.g. #team-a, or an email address")
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	grantSourceConfig = "config"
+	grantSourceStore  = "store"
+)
+
+var (
+	grantsExpiredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grants_expired_total",
+		Help: "Count of expired time-bound grants",
+	}, []string{"source"})
+
+	temporaryGrants = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "temporary_grants",
+		Help: "Number of stored temporary grants",
+	})
+)
+
+var (
+	configReloadsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Count of configuration load attempts",
+	})
+
+	configReloadFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "config_reload_failures_total",
+		Help: "Count of configuration loads that failed and kept the previous configuration",
+	})
+
+	configLastReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "config_last_reload_success_timestamp_seconds",
+		Help: "Unix time of the last successful configuration load",
+	})
+
+	configInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "config_info",
+		Help: "Content hash of the active configuration, the value is always 1",
+	}, []string{"hash"})
+)
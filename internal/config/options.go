@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+type Option func(c *Config)
+
+// WithGrantStore enables temporary grants persisted in store
+func WithGrantStore(store *GrantStore) Option {
+	return func(c *Config) {
+		c.store = store
+		temporaryGrants.Set(float64(store.Len()))
+	}
+}
+
+// WithMaxGrantDuration limits the time window of temporary grants, from
+// notBefore or their creation until they expire, to max
+func WithMaxGrantDuration(max time.Duration) Option {
+	return func(c *Config) {
+		c.maxGrantDuration = max
+	}
+}
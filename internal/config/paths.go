@@ -0,0 +1,11 @@
+package config
+
+import "strings"
+
+// PathHasPrefix reports whether path lies within prefix, matching whole path
+// segments so that /foo/bar is within /foo but /foobar is not
+func PathHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
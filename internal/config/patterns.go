@@ -0,0 +1,292 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// invalidationWildcard ends CloudFront invalidation paths covering every
+// path starting with the characters before it
+const invalidationWildcard = "*"
+
+// PathPattern matches the paths of a distribution that are not a simple
+// prefix.  A Glob matches whole path segments with path.Match semantics and
+// covers everything beneath the matched segments e.g. /*/team-a.  A Regex is
+// anchored at the start of the path and covers every path it matches a
+// prefix of, unless it ends with $ e.g. /v[0-9]+/api/
+type PathPattern struct {
+	Glob  string `json:"glob,omitempty"`
+	Regex string `json:"regex,omitempty"`
+
+	segments []string
+	re       *regexp.Regexp
+	// literal begins every match of the regex, complete when it is the only match
+	literal         string
+	literalComplete bool
+	// endAssertion is set when the regex inspects what follows its match
+	endAssertion bool
+}
+
+func (p *PathPattern) String() string {
+	if p.Glob != "" {
+		return "glob " + p.Glob
+	}
+
+	return "regex " + p.Regex
+}
+
+// compile validates the pattern and prepares its matcher
+func (p *PathPattern) compile() error {
+	if (p.Glob == "") == (p.Regex == "") {
+		return errors.New("exactly one of glob or regex is required")
+	}
+
+	if p.Glob != "" {
+		if !strings.HasPrefix(p.Glob, "/") || p.Glob != path.Clean(p.Glob) {
+			return fmt.Errorf("glob %s must be an absolute clean path", p.Glob)
+		}
+
+		if _, err := path.Match(p.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %s: %v", p.Glob, err)
+		}
+
+		p.segments = strings.Split(strings.TrimPrefix(p.Glob, "/"), "/")
+		return nil
+	}
+
+	expr := "^(?:" + p.Regex + ")"
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid regex %s: %v", p.Regex, err)
+	}
+
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("invalid regex %s: %v", p.Regex, err)
+	}
+
+	// the literal prefix is not reported through the start anchor
+	p.literal, p.literalComplete = regexp.MustCompile(p.Regex).LiteralPrefix()
+	p.re = re
+	p.endAssertion = hasEndAssertion(parsed)
+	return nil
+}
+
+func hasEndAssertion(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEndLine, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+
+	for _, sub := range re.Sub {
+		if hasEndAssertion(sub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// covers reports whether the cleaned invalidation path lies within the
+// pattern.  A path ending with the wildcard is only covered when every path
+// it invalidates is.
+func (p *PathPattern) covers(invalidationPath string) bool {
+	// patterns are compiled when the configuration is parsed
+	if p.re == nil && p.segments == nil {
+		return false
+	}
+
+	wildcard := strings.HasSuffix(invalidationPath, invalidationWildcard)
+
+	if p.re != nil {
+		if !wildcard {
+			return p.re.MatchString(invalidationPath)
+		}
+
+		// without end assertions a match of the path before the wildcard
+		// is a match of every path starting with it
+		return !p.endAssertion && p.re.MatchString(strings.TrimSuffix(invalidationPath, invalidationWildcard))
+	}
+
+	segments := strings.Split(strings.TrimPrefix(invalidationPath, "/"), "/")
+	if !wildcard {
+		return p.matchesSegments(segments)
+	}
+
+	// the segment holding the wildcard may expand to any segment, which
+	// the segments before it cover when they match the whole glob
+	if p.matchesSegments(segments[:len(segments)-1]) {
+		return true
+	}
+
+	// otherwise the last glob segment must match any expansion, which a
+	// trailing * does once it matches the segment without the wildcard
+	if len(segments) != len(p.segments) {
+		return false
+	}
+
+	last := p.segments[len(p.segments)-1]
+	if !strings.HasSuffix(last, "*") || strings.HasSuffix(last, `\*`) {
+		return false
+	}
+
+	segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], invalidationWildcard)
+	return p.matchesSegments(segments)
+}
+
+// matchesSegments reports whether the leading path segments match the glob
+func (p *PathPattern) matchesSegments(segments []string) bool {
+	if len(segments) < len(p.segments) {
+		return false
+	}
+
+	for i, pattern := range p.segments {
+		if ok, _ := path.Match(pattern, segments[i]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// overlapsPrefix reports whether the pattern provably covers a path within prefix
+func (p *PathPattern) overlapsPrefix(prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+
+	if p.re != nil {
+		return p.re.MatchString(prefix) || strings.HasPrefix(p.literal, prefix+"/")
+	}
+
+	segments := strings.Split(strings.TrimPrefix(prefix, "/"), "/")
+	for i := 0; i < len(segments) && i < len(p.segments); i++ {
+		if ok, _ := path.Match(p.segments[i], segments[i]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// overlapsPattern reports whether both patterns provably cover a common path
+func (p *PathPattern) overlapsPattern(other *PathPattern) bool {
+	if p.re == nil && other.re == nil {
+		for i := 0; i < len(p.segments) && i < len(other.segments); i++ {
+			if !segmentsIntersect(p.segments[i], other.segments[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, witness := range p.witnesses() {
+		if other.coversWitness(witness) {
+			return true
+		}
+	}
+
+	for _, witness := range other.witnesses() {
+		if p.coversWitness(witness) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// witnesses returns paths known to be matched by the pattern
+func (p *PathPattern) witnesses() []string {
+	if p.re != nil {
+		if p.literalComplete {
+			return []string{p.literal}
+		}
+
+		return nil
+	}
+
+	if strings.ContainsAny(p.Glob, `?[\`) {
+		return nil
+	}
+
+	return []string{strings.ReplaceAll(p.Glob, "*", "x")}
+}
+
+func (p *PathPattern) coversWitness(witness string) bool {
+	if p.re != nil {
+		return p.re.MatchString(witness)
+	}
+
+	return p.matchesSegments(strings.Split(strings.TrimPrefix(witness, "/"), "/"))
+}
+
+// segmentsIntersect reports whether two glob segments provably match a common segment
+func segmentsIntersect(a, b string) bool {
+	if a == b || a == "*" || b == "*" {
+		return true
+	}
+
+	if ok, _ := path.Match(a, b); ok && !hasGlobMeta(b) {
+		return true
+	}
+
+	if ok, _ := path.Match(b, a); ok && !hasGlobMeta(a) {
+		return true
+	}
+
+	return false
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// validatePatterns compiles the patterns of the distributions and refuses
+// patterns provably overlapping another distribution on the same ID, unless
+// either distribution allows nesting
+func validatePatterns(names []string, distributions distributionsMap, sources locations) error {
+	for _, name := range names {
+		for i := range distributions[name].Patterns {
+			if err := distributions[name].Patterns[i].compile(); err != nil {
+				return fmt.Errorf("error parsing configuration: distribution %s pattern %d: %v", sources.name(name), i, err)
+			}
+		}
+	}
+
+	for _, aName := range names {
+		for _, bName := range names {
+			a, b := distributions[aName], distributions[bName]
+			if aName == bName || a.ID != b.ID || a.AllowNesting || b.AllowNesting {
+				continue
+			}
+
+			for i := range a.Patterns {
+				pattern := &a.Patterns[i]
+				for _, prefix := range b.PathPrefixes() {
+					if pattern.overlapsPrefix(prefix) {
+						return fmt.Errorf("error parsing configuration: distribution %s pattern: %s overlaps distribution %s prefix: %s on id: %s", sources.name(aName), pattern, sources.name(bName), prefix, a.ID)
+					}
+				}
+
+				// each pair of patterns is compared once
+				if aName > bName {
+					continue
+				}
+
+				for j := range b.Patterns {
+					if pattern.overlapsPattern(&b.Patterns[j]) {
+						return fmt.Errorf("error parsing configuration: distribution %s pattern: %s overlaps distribution %s pattern: %s on id: %s", sources.name(aName), pattern, sources.name(bName), &b.Patterns[j], a.ID)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPatternCompile(t *testing.T) {
+	tests := []struct {
+		pattern PathPattern
+		err     string
+	}{
+		{pattern: PathPattern{Glob: "/*/team-a"}},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}},
+		{pattern: PathPattern{}, err: "exactly one of glob or regex is required"},
+		{pattern: PathPattern{Glob: "/*/team-a", Regex: "/v1/"}, err: "exactly one of glob or regex is required"},
+		{pattern: PathPattern{Glob: "*/team-a"}, err: "glob */team-a must be an absolute clean path"},
+		{pattern: PathPattern{Glob: "/*/team-a/"}, err: "glob /*/team-a/ must be an absolute clean path"},
+		{pattern: PathPattern{Glob: "/[a-/team-a"}, err: "invalid glob /[a-/team-a: syntax error in pattern"},
+		{pattern: PathPattern{Regex: "/v[0-9/"}, err: "invalid regex /v[0-9/: error parsing regexp: missing closing ]: `[0-9/)`"},
+	}
+
+	for _, test := range tests {
+		err := test.pattern.compile()
+		if test.err == "" {
+			assert.NoError(t, err, test.pattern.String())
+		} else {
+			assert.EqualError(t, err, test.err, test.pattern.String())
+		}
+	}
+}
+
+func TestPathPatternCovers(t *testing.T) {
+	tests := []struct {
+		pattern PathPattern
+		path    string
+		want    bool
+	}{
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/team-a", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/team-a/index.html", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/team-a/*", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/team-ab", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/team-a*", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/*", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/*", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a"}, path: "/en/fr/team-a", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a/*"}, path: "/en/team-a/*", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a/*"}, path: "/en/team-a", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a*"}, path: "/en/team-a*", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a*"}, path: "/en/team-ab*", want: true},
+		{pattern: PathPattern{Glob: "/*/team-a*"}, path: "/en/team*", want: false},
+		{pattern: PathPattern{Glob: "/*/team-a?"}, path: "/en/team-a*", want: false},
+		{pattern: PathPattern{Glob: "/??/team-a"}, path: "/en/team-a/img/logo.png", want: true},
+		{pattern: PathPattern{Glob: "/??/team-a"}, path: "/eng/team-a", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}, path: "/v12/api/users", want: true},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}, path: "/v12/api/*", want: true},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}, path: "/v1*", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}, path: "/docs/v1/api/users", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/"}, path: "/vx/api/users", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/index\\.html$"}, path: "/v1/api/index.html", want: true},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/index\\.html$"}, path: "/v1/api/index.html.bak", want: false},
+		// the wildcard could invalidate paths the end anchored regex does not match
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/.$"}, path: "/v1/api/*", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/.+"}, path: "/v1/api/*", want: false},
+		{pattern: PathPattern{Regex: "/v[0-9]+/api/.*"}, path: "/v1/api/*", want: true},
+	}
+
+	for _, test := range tests {
+		require.NoError(t, test.pattern.compile())
+		assert.Equal(t, test.want, test.pattern.covers(test.path), "%s %s", test.pattern.String(), test.path)
+	}
+
+	// patterns are only evaluated once compiled
+	assert.False(t, (&PathPattern{Glob: "/*/team-a"}).covers("/en/team-a"))
+}
+
+func TestValidatePatternOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{
+			name: "glob within prefix",
+			yaml: `
+  site:
+    id: "123"
+    prefix: /en
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+`,
+			err: "error parsing configuration: distribution team-a pattern: glob /*/team-a overlaps distribution site prefix: /en on id: 123",
+		},
+		{
+			name: "prefix within glob",
+			yaml: `
+  docs:
+    id: "123"
+    prefix: /en/team-a/docs
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+`,
+			err: "error parsing configuration: distribution team-a pattern: glob /*/team-a overlaps distribution docs prefix: /en/team-a/docs on id: 123",
+		},
+		{
+			name: "glob beside prefix",
+			yaml: `
+  team-b:
+    id: "123"
+    prefix: /en/team-b
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+`,
+		},
+		{
+			name: "glob on another id",
+			yaml: `
+  site:
+    id: "456"
+    prefix: /en
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+`,
+		},
+		{
+			name: "glob within prefix with nesting allowed",
+			yaml: `
+  site:
+    id: "123"
+    prefix: /en
+  team-a:
+    id: "123"
+    allowNesting: true
+    patterns:
+      - glob: /*/team-a
+`,
+		},
+		{
+			name: "intersecting globs",
+			yaml: `
+  english:
+    id: "123"
+    patterns:
+      - glob: /en/*
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+`,
+			err: "error parsing configuration: distribution english pattern: glob /en/* overlaps distribution team-a pattern: glob /*/team-a on id: 123",
+		},
+		{
+			name: "disjoint globs",
+			yaml: `
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+  team-b:
+    id: "123"
+    patterns:
+      - glob: /*/team-b
+`,
+		},
+		{
+			name: "regex matching prefix",
+			yaml: `
+  api:
+    id: "123"
+    patterns:
+      - regex: /v[0-9]+/api/
+  v2:
+    id: "123"
+    prefix: /v2/api/users
+`,
+			err: "error parsing configuration: distribution api pattern: regex /v[0-9]+/api/ overlaps distribution v2 prefix: /v2/api/users on id: 123",
+		},
+		{
+			name: "regex literal within prefix",
+			yaml: `
+  api:
+    id: "123"
+    patterns:
+      - regex: /v1/api/[a-z]+
+  v1:
+    id: "123"
+    prefix: /v1
+`,
+			err: "error parsing configuration: distribution api pattern: regex /v1/api/[a-z]+ overlaps distribution v1 prefix: /v1 on id: 123",
+		},
+		{
+			name: "regex matching glob",
+			yaml: `
+  api:
+    id: "123"
+    patterns:
+      - regex: /v[0-9]+/
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /v1/*
+`,
+			err: "error parsing configuration: distribution api pattern: regex /v[0-9]+/ overlaps distribution team-a pattern: glob /v1/* on id: 123",
+		},
+		{
+			name: "regex beside prefix",
+			yaml: `
+  api:
+    id: "123"
+    patterns:
+      - regex: /v[0-9]+/api/
+  docs:
+    id: "123"
+    prefix: /docs
+`,
+		},
+		{
+			name: "invalid pattern",
+			yaml: `
+  api:
+    id: "123"
+    patterns:
+      - regex: /v[0-9/
+`,
+			err: "error parsing configuration: distribution api pattern 0: invalid regex /v[0-9/: error parsing regexp: missing closing ]: `[0-9/)`",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewTestConfigWithYaml([]byte("distributions:" + test.yaml))
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.EqualError(t, err, test.err, test.name)
+		}
+	}
+}
+
+func TestDistributionPatterns(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  team-a:
+    id: "123"
+    prefix: /assets/team-a
+    patterns:
+      - glob: /*/team-a
+      - regex: /v[0-9]+/team-a/
+entitlements:
+  team-a:
+    - distribution: team-a
+      paths: [/en/team-a, /v1/team-a]
+`))
+	require.NoError(t, err)
+
+	d := c.Distribution("team-a")
+	assert.Equal(t, []string{"/assets/team-a"}, d.PathPrefixes())
+	assert.True(t, d.Covers("/assets/team-a/*"))
+	assert.True(t, d.Covers("/de/team-a/index.html"))
+	assert.True(t, d.Covers("/v2/team-a/*"))
+	assert.False(t, d.Covers("/de/team-b/index.html"))
+
+	_, err = NewTestConfigWithYaml([]byte(`
+distributions:
+  team-a:
+    id: "123"
+    patterns:
+      - glob: /*/team-a
+entitlements:
+  team-a:
+    - distribution: team-a
+      paths: [/en/team-b]
+`))
+	assert.EqualError(t, err, "error parsing configuration: distribution team-a in entitlement team-a: path /en/team-b is outside of prefixes [] and patterns [glob /*/team-a]")
+}
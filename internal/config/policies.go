@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+)
+
+// policyCostLimit bounds the evaluation cost of a single policy
+const policyCostLimit = 1000000
+
+// Policy is a CEL expression that must evaluate to true for an
+// invalidation to be allowed.  Expressions can reference the variables
+//
+//	identity      map with sub, email, username, serviceAccount, certificate, name, claims,
+//	              issuer and attributes e.g. attributes.repository of GitHub Actions tokens
+//	distribution  map with name, id, prefix and prefixes, the list of all its prefixes
+//	paths         list of the cleaned paths requested for invalidation
+//	now           timestamp of the request
+type Policy struct {
+	Name string `json:"name"`
+	Selector
+	Expression string `json:"expression"`
+	// Message is reported when the policy denies a request
+	Message string `json:"message,omitempty"`
+
+	program cel.Program
+}
+
+// PolicyRequest is the invalidation request policies are evaluated against
+type PolicyRequest struct {
+	Identity         *core.Identity
+	DistributionName string
+	Distribution     *Distribution
+	Paths            []string
+	Now              time.Time
+}
+
+func newPolicyEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("identity", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("distribution", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("paths", cel.ListType(cel.StringType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+}
+
+// compile type checks the expression and prepares its program
+func (p *Policy) compile(env *cel.Env) error {
+	if p.Expression == "" {
+		return errors.New("expression must not be empty")
+	}
+
+	ast, issues := env.Compile(p.Expression)
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+
+	if ast.OutputType() != cel.BoolType {
+		return fmt.Errorf("expression must evaluate to bool, got %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(policyCostLimit))
+	if err != nil {
+		return err
+	}
+
+	p.program = program
+	return nil
+}
+
+func (p *Policy) allows(req PolicyRequest) (bool, error) {
+	claims := req.Identity.Claims
+	if claims == nil {
+		claims = []string{}
+	}
+
+	attributes := req.Identity.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	out, _, err := p.program.Eval(map[string]interface{}{
+		"identity": map[string]interface{}{
+			"sub":            req.Identity.Subject,
+			"email":          req.Identity.Email,
+			"username":       req.Identity.PreferredUsername,
+			"serviceAccount": req.Identity.ServiceAccount,
+			"certificate":    req.Identity.Certificate,
+			"name":           req.Identity.Name(),
+			"claims":         claims,
+			"issuer":         req.Identity.Issuer,
+			"attributes":     attributes,
+		},
+		"distribution": map[string]interface{}{
+			"name":     req.DistributionName,
+			"id":       req.Distribution.ID,
+			"prefix":   req.Distribution.Prefix,
+			"prefixes": req.Distribution.PathPrefixes(),
+		},
+		"paths": req.Paths,
+		"now":   req.Now,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %v instead of bool", out.Value())
+	}
+
+	return allowed, nil
+}
+
+func validatePolicies(policies []*Policy, distributions distributionsMap) error {
+	env, err := newPolicyEnv()
+	if err != nil {
+		return fmt.Errorf("error parsing configuration: %v", err)
+	}
+
+	uniqueMap := make(map[string]struct{})
+
+	for _, policy := range policies {
+		if policy.Name == "" {
+			return fmt.Errorf("error parsing configuration: policy name must not be empty")
+		}
+
+		if _, ok := uniqueMap[policy.Name]; ok {
+			return fmt.Errorf("error parsing configuration: policy duplicated name: %s", policy.Name)
+		}
+		uniqueMap[policy.Name] = struct{}{}
+
+		if err := policy.Selector.validate(distributions); err != nil {
+			return fmt.Errorf("error parsing configuration: policy %s: %v", policy.Name, err)
+		}
+
+		if err := policy.compile(env); err != nil {
+			return fmt.Errorf("error parsing configuration: policy %s: %v", policy.Name, err)
+		}
+	}
+
+	return nil
+}
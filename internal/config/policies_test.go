@@ -0,0 +1,192 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyValidation(t *testing.T) {
+	tests := []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: `
+policies:
+  - expression: "true"
+`,
+			err: "error parsing configuration: policy name must not be empty",
+		},
+		{
+			yaml: `
+policies:
+  - name: p1
+    expression: "true"
+  - name: p1
+    expression: "false"
+`,
+			err: "error parsing configuration: policy duplicated name: p1",
+		},
+		{
+			yaml: `
+policies:
+  - name: p1
+`,
+			err: "error parsing configuration: policy p1: expression must not be empty",
+		},
+		{
+			yaml: `
+policies:
+  - name: p1
+    distributions: [dis3]
+    expression: "true"
+`,
+			err: "error parsing configuration: policy p1: distribution dis3 is not configured",
+		},
+		{
+			yaml: `
+policies:
+  - name: p1
+    expression: "size(paths)"
+`,
+			err: "error parsing configuration: policy p1: expression must evaluate to bool, got int",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+` + test.yaml))
+		assert.EqualError(t, err, test.err)
+	}
+
+	// syntax and type errors are reported by the compiler
+	for _, expression := range []string{"size(paths) <=", "unknown == 1", "paths.size() > \"1\""} {
+		_, err := NewTestConfigWithYaml([]byte(`
+policies:
+  - name: p1
+    expression: '` + expression + `'
+`))
+		assert.ErrorContains(t, err, "error parsing configuration: policy p1: ERROR", expression)
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	c, err := NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: "/foo"
+  dis2:
+    id: "456"
+    prefix: "/bar"
+policies:
+  - name: max-paths
+    claims: [scope-y]
+    expression: size(paths) <= 2
+    message: at most 2 paths may be submitted
+  - name: business-hours
+    claims: [group-x]
+    distributions: [dis1]
+    expression: |
+      paths.all(p, !p.endsWith("*")) ||
+      (now.getDayOfWeek("America/New_York") in [1, 2, 3, 4, 5] &&
+       now.getHours("America/New_York") >= 9 && now.getHours("America/New_York") < 17)
+  - name: identity
+    distributions: [dis2]
+    expression: identity.name != "jdoe" && distribution.prefix == "/bar"
+  - name: broken
+    claims: [broken]
+    expression: identity.missing == "x"
+  - name: ci-batches
+    serviceAccounts: [ci-deployer]
+    users:
+      preferred_username: [jdoe]
+    distributions: [dis1]
+    expression: size(paths) <= 1
+`))
+	assert.NoError(t, err)
+
+	dis1 := c.Distribution("dis1")
+	dis2 := c.Distribution("dis2")
+
+	// Wednesday
+	businessHours := time.Date(2026, 10, 14, 14, 0, 0, 0, time.UTC)
+	night := time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		req        PolicyRequest
+		wantPolicy string
+		wantErr    bool
+	}{
+		{
+			name: "path limit allows",
+			req:  PolicyRequest{Identity: &core.Identity{Claims: []string{"scope-y"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a", "/foo/b"}},
+		},
+		{
+			name:       "path limit denies",
+			req:        PolicyRequest{Identity: &core.Identity{Claims: []string{"scope-y"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a", "/foo/b", "/foo/c"}},
+			wantPolicy: "max-paths",
+		},
+		{
+			name: "wildcards during business hours",
+			req:  PolicyRequest{Identity: &core.Identity{Claims: []string{"group-x"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/*"}, Now: businessHours},
+		},
+		{
+			name: "no wildcards at night",
+			req:  PolicyRequest{Identity: &core.Identity{Claims: []string{"group-x"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a"}, Now: night},
+		},
+		{
+			name:       "wildcards at night",
+			req:        PolicyRequest{Identity: &core.Identity{Claims: []string{"group-x"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/*"}, Now: night},
+			wantPolicy: "business-hours",
+		},
+		{
+			name:       "identity and distribution variables",
+			req:        PolicyRequest{Identity: &core.Identity{PreferredUsername: "jdoe"}, DistributionName: "dis2", Distribution: dis2, Paths: []string{"/bar/a"}},
+			wantPolicy: "identity",
+		},
+		{
+			name:       "service account selected",
+			req:        PolicyRequest{Identity: &core.Identity{ServiceAccount: "ci-deployer"}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a", "/foo/b"}},
+			wantPolicy: "ci-batches",
+		},
+		{
+			name:       "user selected",
+			req:        PolicyRequest{Identity: &core.Identity{PreferredUsername: "jdoe"}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a", "/foo/b"}},
+			wantPolicy: "ci-batches",
+		},
+		{
+			name: "other service account",
+			req:  PolicyRequest{Identity: &core.Identity{ServiceAccount: "ci-other"}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a", "/foo/b"}},
+		},
+		{
+			name:       "evaluation errors deny",
+			req:        PolicyRequest{Identity: &core.Identity{Claims: []string{"broken"}}, DistributionName: "dis1", Distribution: dis1, Paths: []string{"/foo/a"}},
+			wantPolicy: "broken",
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		policy, err := c.EvaluatePolicies(test.req)
+		if test.wantErr {
+			assert.Error(t, err, test.name)
+		} else {
+			assert.NoError(t, err, test.name)
+		}
+
+		if test.wantPolicy == "" {
+			assert.Nil(t, policy, test.name)
+		} else if assert.NotNil(t, policy, test.name) {
+			assert.Equal(t, test.wantPolicy, policy.Name, test.name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"time"
+)
+
+// Info describes the active configuration and the outcome of the last reload
+type Info struct {
+	// Hash is the SHA-256 of the content of the active configuration
+	Hash string `json:"hash"`
+	// LoadedAt is the time the active configuration was loaded
+	LoadedAt time.Time `json:"loadedAt"`
+	// Source is the file or directory the active configuration was loaded from
+	Source string `json:"source"`
+	// LastReloadError is the error of the last reload, empty when it succeeded
+	LastReloadError string `json:"lastReloadError,omitempty"`
+}
+
+// Info returns the description of the active configuration
+func (c *Config) Info() Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.info
+}
+
+// contentHash hashes the configuration files, names are included so that
+// moving a document to another file of a directory changes the hash
+func contentHash(files []string, contents [][]byte) string {
+	h := sha256.New()
+	for i, data := range contents {
+		if len(files) > 1 {
+			h.Write([]byte(filepath.Base(files[i])))
+			h.Write([]byte{0})
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loaded records the source and hash of the configuration just applied
+func (c *Config) loaded(source, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.staticHash = hash
+	c.info.Source = source
+	c.updateHash()
+}
+
+// discoveredLoaded records the hash of the discovered distributions just
+// applied
+func (c *Config) discoveredLoaded(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hash == c.discoveredHash {
+		return
+	}
+
+	c.discoveredHash = hash
+	c.updateHash()
+}
+
+// updateHash combines the hashes of the static configuration and of the
+// discovered distributions, mu must be held
+func (c *Config) updateHash() {
+	c.info.Hash = c.staticHash
+	if c.discoveredHash != "" {
+		c.info.Hash = contentHash([]string{"static", "discovered"}, [][]byte{[]byte(c.staticHash), []byte(c.discoveredHash)})
+	}
+	c.info.LoadedAt = time.Now()
+}
+
+// reload runs load, counting the attempt and recording its outcome.  A failed
+// reload keeps the previous configuration active.
+func (c *Config) reload(load func() error) error {
+	configReloadsTotal.Inc()
+
+	err := load()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		configReloadFailuresTotal.Inc()
+		c.info.LastReloadError = err.Error()
+		return err
+	}
+
+	c.info.LastReloadError = ""
+	configLastReloadSuccess.Set(float64(c.info.LoadedAt.Unix()))
+	configInfo.Reset()
+	configInfo.WithLabelValues(c.info.Hash).Set(1)
+
+	return nil
+}
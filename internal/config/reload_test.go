@@ -0,0 +1,73 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(teamAConfig), 0o600))
+
+	attempts := testutil.ToFloat64(configReloadsTotal)
+	failures := testutil.ToFloat64(configReloadFailuresTotal)
+
+	config := New()
+	require.NoError(t, config.reload(func() error { return config.load(file) }))
+
+	sum := sha256.Sum256(append([]byte(teamAConfig), 0))
+	hash := hex.EncodeToString(sum[:])
+
+	info := config.Info()
+	assert.Equal(t, hash, info.Hash)
+	assert.Equal(t, file, info.Source)
+	assert.False(t, info.LoadedAt.IsZero())
+	assert.Empty(t, info.LastReloadError)
+	assert.Equal(t, attempts+1, testutil.ToFloat64(configReloadsTotal))
+	assert.Equal(t, failures, testutil.ToFloat64(configReloadFailuresTotal))
+	assert.Equal(t, float64(info.LoadedAt.Unix()), testutil.ToFloat64(configLastReloadSuccess))
+	assert.Equal(t, float64(1), testutil.ToFloat64(configInfo.WithLabelValues(hash)))
+
+	// a failed reload keeps the active configuration and reports the error
+	require.NoError(t, os.WriteFile(file, []byte("distributions:\n  team-a:\n    prefx: /team-a\n"), 0o600))
+	assert.Error(t, config.reload(func() error { return config.load(file) }))
+
+	failed := config.Info()
+	assert.Equal(t, info.Hash, failed.Hash)
+	assert.Equal(t, info.LoadedAt, failed.LoadedAt)
+	assert.Equal(t, "error parsing configuration: unknown key distributions.team-a.prefx at line 3", failed.LastReloadError)
+	assert.NotNil(t, config.Distribution("team-a"))
+	assert.Equal(t, attempts+2, testutil.ToFloat64(configReloadsTotal))
+	assert.Equal(t, failures+1, testutil.ToFloat64(configReloadFailuresTotal))
+
+	require.NoError(t, os.WriteFile(file, []byte(teamBConfig), 0o600))
+	require.NoError(t, config.reload(func() error { return config.load(file) }))
+
+	reloaded := config.Info()
+	assert.NotEqual(t, info.Hash, reloaded.Hash)
+	assert.Empty(t, reloaded.LastReloadError)
+	assert.Equal(t, 1, testutil.CollectAndCount(configInfo))
+}
+
+func TestReloadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{"team-a.yaml": teamAConfig, "team-b.yaml": teamBConfig})
+
+	config := New()
+	require.NoError(t, config.reload(func() error { return config.loadDir(dir) }))
+	info := config.Info()
+	assert.Equal(t, dir, info.Source)
+	assert.Len(t, info.Hash, 64)
+
+	// the same content in other files is another configuration
+	require.NoError(t, os.Rename(filepath.Join(dir, "team-b.yaml"), filepath.Join(dir, "team-c.yaml")))
+	require.NoError(t, config.reload(func() error { return config.loadDir(dir) }))
+	assert.NotEqual(t, info.Hash, config.Info().Hash)
+}
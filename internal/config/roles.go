@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+type Role string
+
+const (
+	// RoleViewer may read the status of invalidations
+	RoleViewer Role = "viewer"
+	// RoleInvalidator may create invalidations and read their status
+	RoleInvalidator Role = "invalidator"
+	// RoleAdmin may create and read invalidations and manage the distribution
+	RoleAdmin Role = "admin"
+
+	// DefaultRole is assigned to grants without a role
+	DefaultRole = RoleInvalidator
+)
+
+type Permission string
+
+const (
+	PermissionReadInvalidation   Permission = "invalidations:read"
+	PermissionCreateInvalidation Permission = "invalidations:create"
+	PermissionManageDistribution Permission = "distributions:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:      {PermissionReadInvalidation},
+	RoleInvalidator: {PermissionReadInvalidation, PermissionCreateInvalidation},
+	RoleAdmin:       {PermissionReadInvalidation, PermissionCreateInvalidation, PermissionManageDistribution},
+}
+
+// Allows reports whether the role grants permission
+func (r Role) Allows(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Includes reports whether the role grants every permission of other
+func (r Role) Includes(other Role) bool {
+	for _, p := range rolePermissions[other] {
+		if !r.Allows(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate checks the grant against the distribution it refers to
+func (g Grant) validate(distribution *Distribution) error {
+	if err := g.Role.validate(); err != nil {
+		return err
+	}
+
+	if g.NotBefore != nil && g.Expires != nil && !g.Expires.After(*g.NotBefore) {
+		return fmt.Errorf("expires %s must be after notBefore %s", g.Expires.Format(time.RFC3339), g.NotBefore.Format(time.RFC3339))
+	}
+
+	for _, p := range g.Paths {
+		if p != path.Clean(p) || !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("path %s must be an absolute clean path", p)
+		}
+
+		if !distribution.Covers(p) {
+			prefixes := distribution.PathPrefixes()
+			if len(distribution.Patterns) > 0 {
+				patterns := make([]string, 0, len(distribution.Patterns))
+				for i := range distribution.Patterns {
+					patterns = append(patterns, distribution.Patterns[i].String())
+				}
+				return fmt.Errorf("path %s is outside of prefixes %v and patterns %v", p, prefixes, patterns)
+			}
+			if len(prefixes) > 1 {
+				return fmt.Errorf("path %s is outside of prefixes %v", p, prefixes)
+			}
+			return fmt.Errorf("path %s is outside of prefix %s", p, prefixes[0])
+		}
+	}
+
+	return nil
+}
+
+func (r Role) validate() error {
+	if _, ok := rolePermissions[r]; !ok {
+		return fmt.Errorf("unknown role %q", r)
+	}
+
+	return nil
+}
+
+// Grant entitles a distribution with a role.  In configuration a grant is
+// either the distribution name, which grants the default role, or an object
+// e.g. {distribution: sandbox, role: viewer, paths: [/my/path/sub]}
+type Grant struct {
+	Distribution distributionName `json:"distribution"`
+	Role         Role             `json:"role,omitempty"`
+	// Paths narrow the grant to sub-prefixes of the distribution, all paths when empty
+	Paths []string `json:"paths,omitempty"`
+	// NotBefore and Expires bound the time window in which the grant is active
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+}
+
+// activeAt reports whether the grant is within its time window at t
+func (g Grant) activeAt(t time.Time) bool {
+	if g.NotBefore != nil && t.Before(*g.NotBefore) {
+		return false
+	}
+
+	return !g.expiredAt(t)
+}
+
+// expiredAt reports whether the grant expired at or before t
+func (g Grant) expiredAt(t time.Time) bool {
+	return g.Expires != nil && !t.Before(*g.Expires)
+}
+
+func (g *Grant) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*g = Grant{Distribution: name, Role: DefaultRole}
+		return nil
+	}
+
+	// alias avoids recursing into UnmarshalJSON
+	type grant Grant
+	value := grant{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	if value.Role == "" {
+		value.Role = DefaultRole
+	}
+
+	*g = Grant(value)
+
+	return nil
+}
+
+// Grants are the grants held on a single distribution
+type Grants []Grant
+
+// Allows reports whether any grant allows permission
+func (g Grants) Allows(permission Permission) bool {
+	for _, grant := range g {
+		if grant.Role.Allows(permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsRole reports whether any grant includes every permission of role
+func (g Grants) AllowsRole(role Role) bool {
+	for _, grant := range g {
+		if grant.Role.Includes(role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Window returns the time window the grants cover together, from the
+// earliest notBefore to the latest expires.  A side is nil when a grant is
+// unbounded on it.
+func (g Grants) Window() (notBefore *time.Time, expires *time.Time) {
+	for i, grant := range g {
+		if i == 0 || (notBefore != nil && (grant.NotBefore == nil || grant.NotBefore.Before(*notBefore))) {
+			notBefore = grant.NotBefore
+		}
+		if i == 0 || (expires != nil && (grant.Expires == nil || grant.Expires.After(*expires))) {
+			expires = grant.Expires
+		}
+	}
+
+	return notBefore, expires
+}
+
+// PathsAllowed splits paths into the paths covered by the grants and the
+// paths that are not.  A grant without paths covers the whole distribution.
+func (g Grants) PathsAllowed(paths []string) (allowed []string, denied []string) {
+	for _, p := range paths {
+		if g.coversPath(p) {
+			allowed = append(allowed, p)
+		} else {
+			denied = append(denied, p)
+		}
+	}
+
+	return allowed, denied
+}
+
+func (g Grants) coversPath(path string) bool {
+	for _, grant := range g {
+		if len(grant.Paths) == 0 {
+			return true
+		}
+
+		for _, prefix := range grant.Paths {
+			if PathHasPrefix(path, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// GrantedPaths returns the sub-prefixes the grants are narrowed to, or nil
+// when a grant covers the whole distribution
+func (g Grants) GrantedPaths() []string {
+	paths := []string{}
+	for _, grant := range g {
+		if len(grant.Paths) == 0 {
+			return nil
+		}
+		paths = append(paths, grant.Paths...)
+	}
+
+	return paths
+}
+
+// With returns the grants allowing permission
+func (g Grants) With(permission Permission) Grants {
+	ret := Grants{}
+	for _, grant := range g {
+		if grant.Role.Allows(permission) {
+			ret = append(ret, grant)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaID identifies the JSON Schema of the configuration format
+const SchemaID = "https://raw.githubusercontent.com/kanopy-platform/cdnvalidator/main/config/config.schema.json"
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	grantType        = reflect.TypeOf(Grant{})
+	roleType         = reflect.TypeOf(Role(""))
+	timeType         = reflect.TypeOf(time.Time{})
+	distributionType = reflect.TypeOf(Distribution{})
+	pathPatternType  = reflect.TypeOf(PathPattern{})
+)
+
+// schema is a JSON Schema object, keys are sorted when it is marshaled
+type schema map[string]interface{}
+
+// schemaGenerator derives schemas from the Go types configuration is decoded
+// into.  Named struct types are defined once under $defs and referenced.
+type schemaGenerator struct {
+	defs map[string]schema
+}
+
+// Schema returns the JSON Schema of the configuration format generated from
+// the types it is decoded into.  Fields without omitempty are required and
+// unknown keys are rejected, as they are when loading configuration.
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: make(map[string]schema)}
+
+	root := g.object(reflect.TypeOf(document{}))
+	// every section of a configuration file is optional
+	delete(root, "required")
+
+	root["$schema"] = schemaDraft
+	root["$id"] = SchemaID
+	root["title"] = "cdnvalidator configuration"
+	root["$defs"] = g.defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// of returns the schema of a value of type t
+func (g *schemaGenerator) of(t reflect.Type) schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}
+	case roleType:
+		return schema{"type": "string", "enum": []Role{RoleViewer, RoleInvalidator, RoleAdmin}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": g.of(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": g.of(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+
+	return schema{}
+}
+
+// ref defines the named struct type under $defs and returns a reference to it
+func (g *schemaGenerator) ref(t reflect.Type) schema {
+	reference := schema{"$ref": "#/$defs/" + t.Name()}
+	if _, ok := g.defs[t.Name()]; ok {
+		return reference
+	}
+
+	// reserve the name before recursing into the fields
+	g.defs[t.Name()] = schema{}
+
+	def := g.object(t)
+	switch t {
+	case grantType:
+		// a grant is either the distribution name or an object
+		def = schema{"oneOf": []schema{{"type": "string"}, def}}
+	case distributionType:
+		def["anyOf"] = []schema{
+			{"required": []string{"prefix"}},
+			{"required": []string{"prefixes"}},
+			{"required": []string{"patterns"}},
+		}
+		def["not"] = schema{"required": []string{"prefix", "prefixes"}}
+	case pathPatternType:
+		def["oneOf"] = []schema{
+			{"required": []string{"glob"}},
+			{"required": []string{"regex"}},
+		}
+	}
+	g.defs[t.Name()] = def
+
+	return reference
+}
+
+// object returns the schema of the fields of a struct type
+func (g *schemaGenerator) object(t reflect.Type) schema {
+	properties := make(map[string]schema)
+	required := []string{}
+	g.fields(t, properties, &required)
+	sort.Strings(required)
+
+	s := schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
+
+// fields adds the properties of a struct type, including embedded structs
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, properties, required)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.of(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	require.NoError(t, err)
+
+	published, err := os.ReadFile("../../config/config.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(published), string(data), "config/config.schema.json is outdated, run make schema-gen")
+
+	s := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, false, s["additionalProperties"])
+	assert.NotContains(t, s, "required")
+
+	defs := s["$defs"].(map[string]interface{})
+	distribution := defs["Distribution"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"id"}, distribution["required"])
+	assert.Equal(t, false, distribution["additionalProperties"])
+	assert.Len(t, distribution["anyOf"], 3)
+
+	grant := defs["Grant"].(map[string]interface{})
+	assert.Len(t, grant["oneOf"], 2)
+
+	role := grant["oneOf"].([]interface{})[1].(map[string]interface{})["properties"].(map[string]interface{})["role"]
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"viewer", "invalidator", "admin"}}, role)
+}
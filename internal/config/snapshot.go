@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+)
+
+// grantIndex maps a claim or user to its grants on configured distributions
+type grantIndex map[string][]Grant
+
+// Snapshot is an immutable view of a loaded configuration.  A reload swaps
+// in a new snapshot, so lookups made on the same snapshot never mix the
+// previous and the new configuration.
+type Snapshot struct {
+	distributions   distributionsMap
+	entitlements    entitlementsMap
+	users           userEntitlements
+	serviceAccounts serviceAccountsMap
+	claims          []ClaimMapping
+	deny            []DenyRule
+	policies        []*Policy
+	githubActions   []GitHubActionsBinding
+	store           *GrantStore
+
+	// indexes precomputed from the entitlements for DistributionsFromClaims
+	byClaim             grantIndex
+	bySubject           grantIndex
+	byEmail             grantIndex
+	byPreferredUsername grantIndex
+}
+
+// newSnapshot builds the snapshot of a validated configuration document
+func newSnapshot(config *document, store *GrantStore) *Snapshot {
+	s := &Snapshot{
+		distributions:   make(distributionsMap, len(config.Distributions)),
+		entitlements:    make(entitlementsMap, len(config.Entitlements)),
+		serviceAccounts: make(serviceAccountsMap, len(config.ServiceAccounts)),
+		claims:          defaultClaimMappings,
+		deny:            config.Deny,
+		policies:        config.Policies,
+		githubActions:   config.GitHubActions,
+		store:           store,
+	}
+
+	for name, value := range config.Distributions {
+		s.distributions[name] = value
+	}
+
+	for name, value := range config.Entitlements {
+		s.entitlements[name] = value
+	}
+
+	s.users = userEntitlements{
+		Subject:           make(entitlementsMap, len(config.Users.Subject)),
+		Email:             make(entitlementsMap, len(config.Users.Email)),
+		PreferredUsername: make(entitlementsMap, len(config.Users.PreferredUsername)),
+	}
+	for name, value := range config.Users.Subject {
+		s.users.Subject[name] = value
+	}
+	for name, value := range config.Users.Email {
+		// email addresses are matched case insensitively
+		email := strings.ToLower(name)
+		s.users.Email[email] = append(s.users.Email[email], value...)
+	}
+	for name, value := range config.Users.PreferredUsername {
+		s.users.PreferredUsername[name] = value
+	}
+
+	for name, value := range config.ServiceAccounts {
+		s.serviceAccounts[name] = value
+	}
+
+	if len(config.Claims) > 0 {
+		s.claims = config.Claims
+	}
+
+	s.byClaim = s.index(s.entitlements)
+	s.bySubject = s.index(s.users.Subject)
+	s.byEmail = s.index(s.users.Email)
+	s.byPreferredUsername = s.index(s.users.PreferredUsername)
+
+	return s
+}
+
+// index keeps the grants of entitlements on configured distributions
+func (s *Snapshot) index(entitlements entitlementsMap) grantIndex {
+	index := make(grantIndex, len(entitlements))
+	for name, grants := range entitlements {
+		for _, grant := range grants {
+			if _, ok := s.distributions[grant.Distribution]; ok {
+				index[name] = append(index[name], grant)
+			}
+		}
+	}
+
+	return index
+}
+
+// ClaimsFromToken extracts the claims used to look up entitlements from a
+// token according to the configured claim mappings
+func (s *Snapshot) ClaimsFromToken(token *jwt.Claims) []string {
+	mappings := s.claims
+	if len(mappings) == 0 {
+		mappings = defaultClaimMappings
+	}
+
+	claims := []string{}
+	for _, mapping := range mappings {
+		for _, value := range token.Values(mapping.Path) {
+			claims = append(claims, mapping.Prefix+value)
+		}
+	}
+
+	return claims
+}
+
+// DistributionsFromClaims returns a lookup map of Distribution names to the
+// grants the identity holds on them by its claims, by user entitlements or
+// by workload bindings
+func (s *Snapshot) DistributionsFromClaims(identity *core.Identity) map[string]Grants {
+	lookup := make(map[string]Grants)
+	now := time.Now()
+
+	// indexed grants are known to be on configured distributions
+	addIndexed := func(grants []Grant) {
+		for _, grant := range grants {
+			// time-bound grants are ignored outside of their window
+			if grant.activeAt(now) {
+				lookup[grant.Distribution] = append(lookup[grant.Distribution], grant)
+			}
+		}
+	}
+
+	addDistributions := func(grants []Grant) {
+		for _, grant := range grants {
+			if _, ok := s.distributions[grant.Distribution]; ok && grant.activeAt(now) {
+				lookup[grant.Distribution] = append(lookup[grant.Distribution], grant)
+			}
+		}
+	}
+
+	for _, claim := range identity.Claims {
+		addIndexed(s.byClaim[claim])
+	}
+
+	if identity.Subject != "" {
+		addIndexed(s.bySubject[identity.Subject])
+	}
+	if identity.Email != "" {
+		addIndexed(s.byEmail[strings.ToLower(identity.Email)])
+	}
+	if identity.PreferredUsername != "" {
+		addIndexed(s.byPreferredUsername[identity.PreferredUsername])
+	}
+	if identity.ServiceAccount != "" {
+		if account, ok := s.serviceAccounts[identity.ServiceAccount]; ok {
+			addDistributions(account.Entitlements)
+		}
+	}
+	if identity.Issuer != "" {
+		for i := range s.githubActions {
+			if s.githubActions[i].matches(identity) {
+				addDistributions(s.githubActions[i].Entitlements)
+			}
+		}
+	}
+
+	// temporary grants may outlive the distribution they were issued on
+	if s.store != nil {
+		for name, grants := range s.store.grants.Load().byDistribution {
+			if _, ok := s.distributions[name]; !ok {
+				continue
+			}
+
+			for _, grant := range grants {
+				if grant.Holder.matches(identity) {
+					addIndexed([]Grant{grant.grant()})
+				}
+			}
+		}
+	}
+
+	return lookup
+}
+
+// Distribution returns a specific Distribution by name
+func (s *Snapshot) Distribution(name string) *Distribution {
+	if entry, ok := s.distributions[name]; ok {
+		return &Distribution{
+			ID:           entry.ID,
+			Prefix:       entry.Prefix,
+			Prefixes:     append([]string(nil), entry.Prefixes...),
+			Patterns:     append([]PathPattern(nil), entry.Patterns...),
+			AllowNesting: entry.AllowNesting,
+			Discovered:   entry.Discovered,
+			Description:  entry.Description,
+			Owners:       append([]string(nil), entry.Owners...),
+			Contact:      entry.Contact,
+			Links:        copyStrings(entry.Links),
+			Labels:       copyStrings(entry.Labels),
+		}
+	}
+
+	return nil
+}
+
+// DeniedPaths returns the name of the first deny rule forbidding the
+// identity to invalidate any of paths on the distribution, and the paths
+// it denies.  An empty rule name means no paths are denied.
+func (s *Snapshot) DeniedPaths(identity *core.Identity, distributionName string, paths []string) (string, []string) {
+	for _, rule := range s.deny {
+		if !rule.matches(identity, distributionName) {
+			continue
+		}
+
+		if denied := rule.deniedPaths(paths); len(denied) > 0 {
+			return rule.Name, denied
+		}
+	}
+
+	return "", nil
+}
+
+// EvaluatePolicies returns the first policy denying the request, or nil
+// when all policies matching the identity and distribution allow it.  A
+// policy failing to evaluate denies the request and is returned along with
+// the error.
+func (s *Snapshot) EvaluatePolicies(req PolicyRequest) (*Policy, error) {
+	for _, policy := range s.policies {
+		if !policy.matches(req.Identity, req.DistributionName) {
+			continue
+		}
+
+		allowed, err := policy.allows(req)
+		if err != nil {
+			return policy, fmt.Errorf("policy %s: %w", policy.Name, err)
+		}
+
+		if !allowed {
+			return policy, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Snapshot returns the active configuration.  Requests take a single
+// snapshot so that a concurrent reload cannot change the configuration
+// they are evaluated against.
+func (c *Config) Snapshot() *Snapshot {
+	if s := c.snapshot.Load(); s != nil {
+		return s
+	}
+
+	// nothing is loaded yet
+	return &Snapshot{store: c.store}
+}
+
+// ClaimsFromToken extracts the claims of the active configuration from a token
+func (c *Config) ClaimsFromToken(token *jwt.Claims) []string {
+	return c.Snapshot().ClaimsFromToken(token)
+}
+
+// DistributionsFromClaims returns the distributions the identity is granted
+// by the active configuration
+func (c *Config) DistributionsFromClaims(identity *core.Identity) map[string]Grants {
+	return c.Snapshot().DistributionsFromClaims(identity)
+}
+
+// Distribution returns a specific Distribution of the active configuration by name
+func (c *Config) Distribution(name string) *Distribution {
+	return c.Snapshot().Distribution(name)
+}
+
+// DeniedPaths evaluates the deny rules of the active configuration
+func (c *Config) DeniedPaths(identity *core.Identity, distributionName string, paths []string) (string, []string) {
+	return c.Snapshot().DeniedPaths(identity, distributionName, paths)
+}
+
+// EvaluatePolicies evaluates the policies of the active configuration
+func (c *Config) EvaluatePolicies(req PolicyRequest) (*Policy, error) {
+	return c.Snapshot().EvaluatePolicies(req)
+}
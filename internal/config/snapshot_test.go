@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	config := emptyConfig()
+	assert.Nil(t, config.Distribution("team-a"))
+	assert.Empty(t, config.DistributionsFromClaims(&core.Identity{Claims: []string{"team-a"}}))
+
+	require.NoError(t, config.parse([]byte(teamAConfig)))
+	snapshot := config.Snapshot()
+
+	require.NoError(t, config.parse([]byte(teamBConfig)))
+
+	// a snapshot is not affected by later reloads
+	identity := &core.Identity{Claims: []string{"team-a", "team-b"}}
+	assert.NotNil(t, snapshot.Distribution("team-a"))
+	assert.Nil(t, snapshot.Distribution("team-b"))
+	assert.Equal(t, []string{"team-a"}, keys(snapshot.DistributionsFromClaims(identity)))
+
+	assert.Nil(t, config.Distribution("team-a"))
+	assert.NotNil(t, config.Distribution("team-b"))
+	assert.Equal(t, []string{"team-b"}, keys(config.DistributionsFromClaims(identity)))
+}
+
+func TestSnapshotIndex(t *testing.T) {
+	config := emptyConfig()
+	require.NoError(t, config.parse([]byte(`distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+  dis2:
+    id: "123"
+    prefix: /bar
+entitlements:
+  grp1:
+    - dis1
+    - distribution: dis2
+      role: viewer
+users:
+  email:
+    JDoe@example.com:
+      - dis1
+    jdoe@example.com:
+      - dis2
+`)))
+
+	snapshot := config.Snapshot()
+	assert.Equal(t, grantIndex{
+		"grp1": {{Distribution: "dis1", Role: RoleInvalidator}, {Distribution: "dis2", Role: RoleViewer}},
+	}, snapshot.byClaim)
+	assert.Len(t, snapshot.byEmail["jdoe@example.com"], 2)
+	assert.ElementsMatch(t, []string{"dis1", "dis2"}, keys(snapshot.DistributionsFromClaims(&core.Identity{Email: "JDOE@example.com"})))
+}
+
+func TestSnapshotConcurrentReload(t *testing.T) {
+	config := emptyConfig()
+	require.NoError(t, config.parse([]byte(teamAConfig)))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			data := teamAConfig
+			if i%2 == 1 {
+				data = teamBConfig
+			}
+			assert.NoError(t, config.parse([]byte(data)))
+		}
+	}()
+
+	identity := &core.Identity{Claims: []string{"team-a", "team-b"}}
+	for i := 0; i < 1000; i++ {
+		snapshot := config.Snapshot()
+
+		// both lookups see the same configuration
+		for name := range snapshot.DistributionsFromClaims(identity) {
+			assert.NotNil(t, snapshot.Distribution(name))
+		}
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+// benchmarkConfig has n distributions, each granted to its own group and to
+// a shared group
+func benchmarkConfig(n int) []byte {
+	var b strings.Builder
+	b.WriteString("distributions:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "  dis%d:\n    id: \"123\"\n    prefix: /dis%d\n", i, i)
+	}
+
+	b.WriteString("entitlements:\n  shared:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "    - dis%d\n", i)
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "  grp%d:\n    - dis%d\n", i, i)
+	}
+
+	return []byte(b.String())
+}
+
+func benchmarkLookups(b *testing.B, reload bool) {
+	data := benchmarkConfig(100)
+	config := emptyConfig()
+	require.NoError(b, config.parse(data))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if reload {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					if err := config.parse(data); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	identity := &core.Identity{Claims: []string{"grp1", "grp42", "shared"}}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			snapshot := config.Snapshot()
+			if _, ok := snapshot.DistributionsFromClaims(identity)["dis42"]; !ok {
+				b.Error("dis42 not granted")
+			}
+			if snapshot.Distribution("dis42") == nil {
+				b.Error("dis42 not found")
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkLookups(b *testing.B) {
+	benchmarkLookups(b, false)
+}
+
+func BenchmarkLookupsConcurrentReload(b *testing.B) {
+	benchmarkLookups(b, true)
+}
+
+func BenchmarkParse(b *testing.B) {
+	data := benchmarkConfig(100)
+	config := emptyConfig()
+
+	for i := 0; i < b.N; i++ {
+		if err := config.parse(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
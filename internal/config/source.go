@@ -0,0 +1,333 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kanopy-platform/cdnvalidator/pkg/aws/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotModified is returned by a Source when the configuration has not
+// changed since the version last committed
+var ErrNotModified = errors.New("configuration not modified")
+
+// maxConfigSize bounds the configuration read from remote sources, 10 MiB
+const maxConfigSize = 10 << 20
+
+// Source is a location configuration is loaded from and watched for changes
+type Source interface {
+	// Fetch returns the content of the configuration and its version, or
+	// ErrNotModified when it still has the version last committed
+	Fetch(ctx context.Context) ([]byte, string, error)
+	// Commit records the version of a configuration applied successfully,
+	// a configuration which failed to apply is fetched again
+	Commit(version string)
+	// Watch signals on the returned channel when the configuration may
+	// have changed, until ctx is done
+	Watch(ctx context.Context) (<-chan struct{}, error)
+	// String names the source in the configuration info and logs
+	String() string
+}
+
+// notify signals a change without blocking, pending changes are coalesced
+func notify(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// poll signals a possible change every interval until ctx is done
+func poll(ctx context.Context, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				notify(changes)
+			}
+		}
+	}()
+
+	return changes
+}
+
+// FileSource is a local configuration file watched for changes
+type FileSource struct {
+	path string
+}
+
+// NewFileSource returns the source of the configuration file at path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (s *FileSource) String() string {
+	return s.path
+}
+
+// Fetch reads the file, which is always considered modified
+func (s *FileSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(s.path)
+	return data, "", err
+}
+
+// Commit does nothing, files are read on every change
+func (s *FileSource) Commit(version string) {}
+
+// Watch signals writes to the file and its replacement
+func (s *FileSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(s.path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go s.watch(ctx, watcher, changes)
+
+	return changes, nil
+}
+
+func (s *FileSource) watch(ctx context.Context, watcher *fsnotify.Watcher, changes chan<- struct{}) {
+	defer close(changes)
+	defer watcher.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			// Mounted files are symlinks. When the kubelet refreshes the file it is removing
+			// and adding a symlink.  Therefore, when we see a remove event we know that a reload
+			// needs to take place.
+			// https://kubernetes.io/docs/concepts/configuration/secret/#secret-files-permissions
+			if event.Op&fsnotify.Remove == fsnotify.Remove {
+				if err := watcher.Remove(event.Name); err != nil {
+					log.Errorf("error removing watcher from configuration: %v", err)
+				}
+				if err := watcher.Add(event.Name); err != nil {
+					log.Errorf("error re-watching configuration: %v", err)
+				}
+				notify(changes)
+			}
+			if event.Op&fsnotify.Write == fsnotify.Write {
+				notify(changes)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("error on reload watcher: %v", err)
+		}
+	}
+}
+
+// HTTPSource is a configuration file served over HTTP(S), polled with
+// If-None-Match so that an unchanged file is not downloaded again
+type HTTPSource struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	etag     string
+}
+
+// NewHTTPSource returns the source of the configuration file at url polled
+// every interval
+func NewHTTPSource(url string, interval time.Duration) *HTTPSource {
+	return &HTTPSource{
+		url:      url,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		interval: interval,
+	}
+}
+
+func (s *HTTPSource) String() string {
+	return s.url
+}
+
+// Fetch downloads the file unless it still has the ETag last committed
+func (s *HTTPSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", ErrNotModified
+	default:
+		return nil, "", fmt.Errorf("error fetching configuration from %s: %s", s.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxConfigSize {
+		return nil, "", fmt.Errorf("error fetching configuration from %s: exceeds %d bytes", s.url, maxConfigSize)
+	}
+
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Commit records the ETag of the file applied
+func (s *HTTPSource) Commit(version string) {
+	s.etag = version
+}
+
+// Watch polls the file every interval
+func (s *HTTPSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return poll(ctx, s.interval), nil
+}
+
+// ObjectGetter gets S3 objects unless they still have etag
+type ObjectGetter interface {
+	GetObject(ctx context.Context, bucket string, key string, etag string) (*s3.GetObjectOutput, error)
+}
+
+// S3Source is a configuration file stored as an S3 object, polled with ETag
+// comparison so that an unchanged object is not downloaded again
+type S3Source struct {
+	client   ObjectGetter
+	bucket   string
+	key      string
+	interval time.Duration
+	etag     string
+}
+
+// NewS3Source returns the source of the configuration object key in bucket
+// polled every interval
+func NewS3Source(client ObjectGetter, bucket string, key string, interval time.Duration) *S3Source {
+	return &S3Source{
+		client:   client,
+		bucket:   bucket,
+		key:      key,
+		interval: interval,
+	}
+}
+
+func (s *S3Source) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.key)
+}
+
+// Fetch downloads the object unless it still has the ETag last committed
+func (s *S3Source) Fetch(ctx context.Context) ([]byte, string, error) {
+	output, err := s.client.GetObject(ctx, s.bucket, s.key, s.etag)
+	if errors.Is(err, s3.ErrNotModified) {
+		return nil, "", ErrNotModified
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching configuration from %s: %v", s, err)
+	}
+
+	return output.Body, output.ETag, nil
+}
+
+// Commit records the ETag of the object applied
+func (s *S3Source) Commit(version string) {
+	s.etag = version
+}
+
+// Watch polls the object every interval
+func (s *S3Source) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return poll(ctx, s.interval), nil
+}
+
+// loadSource fetches the configuration from source and applies it, the
+// version applied is committed to the source
+func (c *Config) loadSource(ctx context.Context, source Source) error {
+	data, version, err := source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.loadData(source.String(), data); err != nil {
+		return err
+	}
+
+	source.Commit(version)
+	return nil
+}
+
+// WatchSource loads the configuration from source and reloads it when the
+// source changes.  The last configuration loaded successfully stays active
+// while the source is unavailable or serves an invalid configuration.
+func (c *Config) WatchSource(source Source) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	changes, err := source.Watch(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if err := c.reload(func() error { return c.loadSource(ctx, source) }); err != nil {
+		cancel()
+		return fmt.Errorf("error loading configuration: %v", err)
+	}
+
+	go c.sourceWatcher(ctx, cancel, source, changes)
+	return nil
+}
+
+func (c *Config) sourceWatcher(ctx context.Context, cancel context.CancelFunc, source Source, changes <-chan struct{}) {
+	defer cancel()
+	for range changes {
+		data, version, err := source.Fetch(ctx)
+		if errors.Is(err, ErrNotModified) {
+			continue
+		}
+
+		err = c.reload(func() error {
+			if err != nil {
+				return err
+			}
+			if err := c.loadData(source.String(), data); err != nil {
+				return err
+			}
+
+			// a configuration failing to apply is fetched again
+			source.Commit(version)
+			return nil
+		})
+		if err != nil {
+			log.WithField("source", source.String()).Errorf("error refreshing configuration: %v", err)
+		} else {
+			log.WithField("source", source.String()).Info("configuration refreshed")
+		}
+	}
+}
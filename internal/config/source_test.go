@@ -0,0 +1,215 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/pkg/aws/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configServer serves a configuration file at path with an ETag, as a web
+// server or S3 with path style addressing do
+type configServer struct {
+	*httptest.Server
+	path string
+
+	mu        sync.Mutex
+	data      string
+	available bool
+	downloads int
+}
+
+func newConfigServer(t *testing.T, path string, data string) *configServer {
+	s := &configServer{path: path, data: data, available: true}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *configServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.available {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path != s.path {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(s.data)))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	s.downloads++
+	w.Header().Set("ETag", etag)
+	_, _ = w.Write([]byte(s.data))
+}
+
+func (s *configServer) set(data string, available bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = data
+	s.available = available
+}
+
+func (s *configServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.downloads
+}
+
+func TestHTTPSource(t *testing.T) {
+	t.Parallel()
+
+	srv := newConfigServer(t, "/config.yaml", teamAConfig)
+	source := NewHTTPSource(srv.URL+"/config.yaml", time.Hour)
+	ctx := context.Background()
+
+	data, etag, err := source.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, teamAConfig, string(data))
+
+	// the file is downloaded again until its version is committed
+	_, _, err = source.Fetch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.count())
+
+	// an unchanged file is not downloaded again
+	source.Commit(etag)
+	_, _, err = source.Fetch(ctx)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, 2, srv.count())
+
+	srv.set(teamBConfig, true)
+	data, _, err = source.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, teamBConfig, string(data))
+	assert.Equal(t, 3, srv.count())
+
+	srv.set(teamBConfig, false)
+	_, _, err = source.Fetch(ctx)
+	assert.EqualError(t, err, "error fetching configuration from "+srv.URL+"/config.yaml: 503 Service Unavailable")
+
+	_, _, err = NewHTTPSource(srv.URL+"/missing.yaml", time.Hour).Fetch(ctx)
+	assert.Error(t, err)
+
+	// configuration is read up to a maximum size
+	srv.set(strings.Repeat("#", maxConfigSize+1), true)
+	_, _, err = source.Fetch(ctx)
+	assert.EqualError(t, err, fmt.Sprintf("error fetching configuration from %s/config.yaml: exceeds %d bytes", srv.URL, maxConfigSize))
+}
+
+func TestS3Source(t *testing.T) {
+	t.Parallel()
+
+	srv := newConfigServer(t, "/bucket/config.yaml", teamAConfig)
+	client, err := s3.New(s3.WithEndpoint(srv.URL), s3.WithStaticCredentials("key", "secret"))
+	require.NoError(t, err)
+
+	source := NewS3Source(client, "bucket", "config.yaml", time.Hour)
+	assert.Equal(t, "s3://bucket/config.yaml", source.String())
+	ctx := context.Background()
+
+	data, etag, err := source.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, teamAConfig, string(data))
+
+	// an unchanged object is not downloaded again once committed
+	source.Commit(etag)
+	_, _, err = source.Fetch(ctx)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, 1, srv.count())
+
+	srv.set(teamBConfig, true)
+	data, _, err = source.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, teamBConfig, string(data))
+
+	_, _, err = NewS3Source(client, "bucket", "missing.yaml", time.Hour).Fetch(ctx)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotModified)
+}
+
+func TestWatchSource(t *testing.T) {
+	t.Parallel()
+
+	srv := newConfigServer(t, "/config.yaml", teamAConfig)
+	source := NewHTTPSource(srv.URL+"/config.yaml", 10*time.Millisecond)
+
+	c := New()
+	require.NoError(t, c.WatchSource(source))
+	assert.NotNil(t, c.Distribution("team-a"))
+	assert.Equal(t, source.String(), c.Info().Source)
+
+	srv.set(teamBConfig, true)
+	assert.Eventually(t, func() bool { return c.Distribution("team-b") != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, c.Distribution("team-a"))
+	hash := c.Info().Hash
+
+	// an unavailable source keeps the last known-good configuration
+	srv.set(teamBConfig, false)
+	assert.Eventually(t, func() bool { return c.Info().LastReloadError != "" }, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, c.Distribution("team-b"))
+	assert.Equal(t, hash, c.Info().Hash)
+
+	// and so does an invalid configuration
+	srv.set("distributions:\n  team-c:\n    prefx: /team-c\n", true)
+	assert.Eventually(t, func() bool {
+		return c.Info().LastReloadError == "error parsing configuration: unknown key distributions.team-c.prefx at line 3"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, c.Distribution("team-b"))
+
+	// the invalid configuration is not committed and is downloaded again
+	downloads := srv.count()
+	assert.Eventually(t, func() bool { return srv.count() > downloads }, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, c.Distribution("team-b"))
+
+	srv.set(teamAConfig, true)
+	assert.Eventually(t, func() bool { return c.Distribution("team-a") != nil && c.Info().LastReloadError == "" }, 5*time.Second, 10*time.Millisecond)
+
+	// the applied configuration is not downloaded again while unchanged
+	downloads = srv.count()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, downloads, srv.count())
+
+	// the initial configuration must load
+	srv.set(teamAConfig, false)
+	assert.Error(t, New().WatchSource(NewHTTPSource(srv.URL+"/config.yaml", time.Hour)))
+}
+
+func TestWatchFileSource(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(teamAConfig), 0o600))
+
+	c := New()
+	require.NoError(t, c.Watch(file))
+	assert.NotNil(t, c.Distribution("team-a"))
+	assert.Equal(t, file, c.Info().Source)
+
+	require.NoError(t, os.WriteFile(file, []byte(teamBConfig), 0o600))
+	assert.Eventually(t, func() bool { return c.Distribution("team-b") != nil }, 5*time.Second, 10*time.Millisecond)
+
+	assert.Error(t, New().Watch(filepath.Join(t.TempDir(), "missing.yaml")))
+}
@@ -1,7 +1,7 @@
 package config
 
-func NewTestConfigWithYaml(data []byte) (*Config, error) {
-	c := &Config{}
+func NewTestConfigWithYaml(data []byte, opts ...Option) (*Config, error) {
+	c := New(opts...)
 	if err := c.parse(data); err != nil {
 		return nil, err
 	}
@@ -1,28 +1,113 @@
 package config
 
 import (
-	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type distributionName = string
 type claimName = string
 type distributionsMap map[distributionName]*Distribution
-type entitlementsMap map[claimName][]distributionName
+type entitlementsMap map[claimName][]Grant
 
 type Distribution struct {
 	ID     string `json:"id"`
-	Prefix string `json:"prefix"`
+	Prefix string `json:"prefix,omitempty"`
+	// Prefixes are several path prefixes used instead of a single Prefix
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Patterns match paths by glob or regex, alone or next to the prefixes
+	Patterns []PathPattern `json:"patterns,omitempty"`
+	// AllowNesting permits the prefix to lie within the prefix of another
+	// distribution with the same ID, whose entitlements then cover it too
+	AllowNesting bool `json:"allowNesting,omitempty"`
+	// Discovered marks distributions discovered from Cloudfront tags, which
+	// cannot be set in configuration files
+	Discovered bool `json:"-"`
+
+	// Description tells users what the distribution serves
+	Description string `json:"description,omitempty"`
+	// Owners are the teams or people responsible for the distribution
+	Owners []string `json:"owners,omitempty"`
+	// Contact is the Slack channel, e.g. #team-a, or email address of the owners
+	Contact string `json:"contact,omitempty"`
+	// Links are named http(s) URLs, e.g. runbook or site
+	Links map[string]string `json:"links,omitempty"`
+	// Labels are free-form key value pairs
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// PathPrefixes returns the path prefixes of the distribution
+func (d *Distribution) PathPrefixes() []string {
+	if len(d.Prefixes) > 0 {
+		return d.Prefixes
+	}
+
+	// a distribution of patterns alone has no prefix
+	if d.Prefix == "" && len(d.Patterns) > 0 {
+		return nil
+	}
+
+	return []string{d.Prefix}
 }
 
-// StringPropertiesHash concatenates all string properties in Distribution
-// to form a unique hash
-func (d *Distribution) hashKey() string {
-	return fmt.Sprintf("%s%s", d.ID, d.Prefix)
+// Covers reports whether path lies within any prefix or pattern of the distribution
+func (d *Distribution) Covers(path string) bool {
+	for _, prefix := range d.PathPrefixes() {
+		if PathHasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	for i := range d.Patterns {
+		if d.Patterns[i].covers(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userEntitlements grant distributions to individual users
+type userEntitlements struct {
+	Subject           entitlementsMap `json:"sub,omitempty"`
+	Email             entitlementsMap `json:"email,omitempty"`
+	PreferredUsername entitlementsMap `json:"preferred_username,omitempty"`
+}
+
+// ClaimMapping selects a token claim used to look up entitlements
+type ClaimMapping struct {
+	// Path of the claim, nested claims are separated by dots e.g. realm_access.roles
+	Path string `json:"path"`
+	// Prefix is prepended to every value of the claim e.g. group:
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// defaultClaimMappings are used when no claims are configured
+var defaultClaimMappings = []ClaimMapping{
+	{Path: "groups"},
+	{Path: "scp"},
 }
 
 type Config struct {
-	mu            sync.Mutex
-	distributions distributionsMap
-	entitlements  entitlementsMap
+	// snapshot is the active configuration, swapped as a whole on reload
+	snapshot atomic.Pointer[Snapshot]
+	store    *GrantStore
+	// maxGrantDuration limits the time window of temporary grants, unlimited when zero
+	maxGrantDuration time.Duration
+
+	// applyMu serializes applying configuration, the static configuration
+	// last applied is merged with the distributions discovered since
+	applyMu    sync.Mutex
+	static     *document
+	discovered distributionsMap
+
+	// mu guards the bookkeeping below, lookups never take it
+	mu sync.Mutex
+	// reportedExpired tracks configured grants already reported as expired
+	reportedExpired map[string]struct{}
+	info            Info
+	// staticHash and discoveredHash are combined into the hash of the info
+	staticHash     string
+	discoveredHash string
 }
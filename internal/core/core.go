@@ -1,17 +1,136 @@
 package core
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 type ClaimsKey string
 
 const (
 	ContextBoundaryKey ClaimsKey = "claims"
+	AuthSourceKey      ClaimsKey = "authSource"
+)
+
+// AuthSource is how the credentials of a request were presented
+type AuthSource string
+
+const (
+	AuthSourceCookie AuthSource = "cookie"
+	AuthSourceHeader AuthSource = "header"
+	// AuthSourceProxyHeader is the header named by --auth-header, which a
+	// forward auth proxy fills from the session cookie of the browser
+	AuthSourceProxyHeader AuthSource = "proxy-header"
+	AuthSourceAPIKey      AuthSource = "apikey"
+	AuthSourceCertificate AuthSource = "certificate"
+)
+
+// FromBrowser reports whether browsers present the credentials on their own,
+// cross site requests included, so that they need CSRF protection
+func (s AuthSource) FromBrowser() bool {
+	return s == AuthSourceCookie || s == AuthSourceProxyHeader
+}
+
+// Prefixes of the claims of client certificates and Kubernetes service
+// accounts.  Claims mapped from user tokens never carry them, so that a token
+// group cannot impersonate a client certificate or a service account.
+const (
+	CertificateCNClaimPrefix  = "cn:"
+	CertificateOUClaimPrefix  = "ou:"
+	SPIFFEClaimPrefix         = "spiffe://"
+	ServiceAccountClaimPrefix = "system:serviceaccount"
 )
 
-func GetClaims(ctx context.Context) []string {
-	claims, ok := ctx.Value(ContextBoundaryKey).([]string)
-	if !ok {
-		return []string{}
+var reservedClaimPrefixes = []string{CertificateCNClaimPrefix, CertificateOUClaimPrefix, SPIFFEClaimPrefix, ServiceAccountClaimPrefix}
+
+// WithoutReservedClaims returns the claims of a user token without the claims
+// reserved for other authentication methods
+func WithoutReservedClaims(claims []string) []string {
+	ret := []string{}
+	for _, claim := range claims {
+		if !hasReservedPrefix(claim) {
+			ret = append(ret, claim)
+		}
+	}
+
+	return ret
+}
+
+func hasReservedPrefix(claim string) bool {
+	for _, prefix := range reservedClaimPrefixes {
+		if strings.HasPrefix(claim, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Subject is the sub claim of the token
+	Subject string
+	// Email is the verified email address of the caller
+	Email string
+	// PreferredUsername is the preferred_username claim of the token
+	PreferredUsername string
+	// ServiceAccount is the name of the service account authenticated by API key
+	ServiceAccount string
+	// Certificate names the client certificate by its SPIFFE ID or subject CN
+	Certificate string
+	// Issuer is the iss claim of workload tokens such as GitHub Actions tokens
+	Issuer string
+	// Attributes are further claims of workload tokens e.g. repository and ref
+	Attributes map[string]string
+	// Claims are the values entitlements are looked up by
+	Claims []string
+}
+
+// IsEmpty reports whether the identity carries nothing to look up entitlements by
+func (i *Identity) IsEmpty() bool {
+	return len(i.Claims) == 0 && i.Subject == "" && i.Email == "" && i.PreferredUsername == "" && i.ServiceAccount == "" && i.Certificate == ""
+}
+
+// Name returns the most descriptive name of the identity for attribution
+func (i *Identity) Name() string {
+	switch {
+	case i.ServiceAccount != "":
+		return "serviceaccount:" + i.ServiceAccount
+	case i.Certificate != "":
+		return "certificate:" + i.Certificate
+	case i.PreferredUsername != "":
+		return i.PreferredUsername
+	case i.Email != "":
+		return i.Email
+	case i.Subject != "":
+		return i.Subject
+	default:
+		return "-"
+	}
+}
+
+// WithIdentity returns a copy of ctx carrying identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, ContextBoundaryKey, identity)
+}
+
+// GetIdentity returns the identity stored in ctx, or an empty identity
+func GetIdentity(ctx context.Context) *Identity {
+	identity, ok := ctx.Value(ContextBoundaryKey).(*Identity)
+	if !ok || identity == nil {
+		return &Identity{}
 	}
-	return claims
+	return identity
+}
+
+// WithAuthSource returns a copy of ctx carrying the source of the credentials
+func WithAuthSource(ctx context.Context, source AuthSource) context.Context {
+	return context.WithValue(ctx, AuthSourceKey, source)
+}
+
+// GetAuthSource returns the source of the credentials stored in ctx, empty
+// when the request was not authenticated
+func GetAuthSource(ctx context.Context) AuthSource {
+	source, _ := ctx.Value(AuthSourceKey).(AuthSource)
+	return source
 }
@@ -5,90 +5,150 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
-	"sort"
-	"strings"
+	"time"
 
 	"github.com/kanopy-platform/cdnvalidator/internal/config"
 	"github.com/kanopy-platform/cdnvalidator/internal/core"
 	"github.com/kanopy-platform/cdnvalidator/pkg/aws/cloudfront"
+	log "github.com/sirupsen/logrus"
 )
 
 type DistributionService struct {
 	Config     *config.Config
 	Cloudfront *cloudfront.Client
+
+	// now returns the time policies are evaluated at
+	now func() time.Time
 }
 
 func New(config *config.Config, cloudfront *cloudfront.Client) *DistributionService {
 	return &DistributionService{
 		Config:     config,
 		Cloudfront: cloudfront,
+		now:        time.Now,
 	}
 }
 
-func (d *DistributionService) getDistribution(ctx context.Context, distributionName string) (*config.Distribution, error) {
-	claims := core.GetClaims(ctx)
-	if len(claims) == 0 {
-		return nil, errors.New("no claims present")
+// getDistribution returns the distribution and the grants of the user allowing
+// permission on it, both looked up in the same configuration snapshot
+func (d *DistributionService) getDistribution(ctx context.Context, snapshot *config.Snapshot, distributionName string, permission config.Permission) (*config.Distribution, config.Grants, error) {
+	identity := core.GetIdentity(ctx)
+	if identity.IsEmpty() {
+		return nil, nil, errors.New("no claims present")
 	}
 
-	distribution := d.Config.Distribution(distributionName)
+	distribution := snapshot.Distribution(distributionName)
 	if distribution == nil {
-		return nil, NewInvalidationError(ResourceNotFoundErrorCode, fmt.Errorf("distribution %s not found", distributionName), distributionName)
+		return nil, nil, NewInvalidationError(ResourceNotFoundErrorCode, fmt.Errorf("distribution %s not found", distributionName), distributionName)
 	}
 
 	// check user is entitled to the distributionName
-	entitledDistributions := d.Config.DistributionsFromClaims(claims)
-	if _, ok := entitledDistributions[distributionName]; !ok {
-		return nil, NewInvalidationError(InvalidationUnauthorizedErrorCode, fmt.Errorf("distribution unauthorized"), distributionName)
+	entitledDistributions := snapshot.DistributionsFromClaims(identity)
+	grants, ok := entitledDistributions[distributionName]
+	if !ok {
+		return nil, nil, NewInvalidationError(InvalidationUnauthorizedErrorCode, fmt.Errorf("distribution unauthorized"), distributionName)
+	}
+
+	// check the role of the user allows the operation
+	if !grants.Allows(permission) {
+		return nil, nil, NewPermissionError(distributionName, permission)
 	}
 
-	return distribution, nil
+	return distribution, grants.With(permission), nil
 }
 
-func (d *DistributionService) List(ctx context.Context) ([]string, error) {
-	claims := core.GetClaims(ctx)
-	if len(claims) == 0 {
+func (d *DistributionService) List(ctx context.Context) (map[VanityDistributionName]Distribution, error) {
+	identity := core.GetIdentity(ctx)
+	if identity.IsEmpty() {
 		return nil, errors.New("no claims present")
 	}
 
-	distributions := d.Config.DistributionsFromClaims(claims)
+	snapshot := d.Config.Snapshot()
+	distributions := snapshot.DistributionsFromClaims(identity)
 
-	ret := make([]string, 0, len(distributions))
+	ret := make(map[VanityDistributionName]Distribution, len(distributions))
 	for name := range distributions {
-		ret = append(ret, name)
+		distribution := snapshot.Distribution(name)
+		if distribution == nil {
+			continue
+		}
+
+		ret[VanityDistributionName(name)] = NewDistribution(distribution)
 	}
-	sort.Strings(ret)
 
 	return ret, nil
 }
 
+// ConfigInfo describes the active configuration to any authenticated user
+func (d *DistributionService) ConfigInfo(ctx context.Context) (*ConfigInfoResponse, error) {
+	if core.GetIdentity(ctx).IsEmpty() {
+		return nil, errors.New("no claims present")
+	}
+
+	return &ConfigInfoResponse{Info: d.Config.Info()}, nil
+}
+
 func (d *DistributionService) CreateInvalidation(ctx context.Context, distributionName string, paths []string) (*InvalidationResponse, error) {
-	distribution, err := d.getDistribution(ctx, distributionName)
+	// the request is evaluated against a single configuration snapshot
+	snapshot := d.Config.Snapshot()
+	distribution, grants, err := d.getDistribution(ctx, snapshot, distributionName, config.PermissionCreateInvalidation)
 	if err != nil {
 		return nil, err
 	}
 
 	cleanedPaths := make([]string, 0, len(paths))
-	invalidPaths := make([]string, 0)
-
 	for _, p := range paths {
-		cleanedPath := filepath.Clean(p)
+		cleanedPaths = append(cleanedPaths, filepath.Clean(p))
+	}
+
+	// deny rules take precedence over any entitlement
+	if rule, deniedPaths := snapshot.DeniedPaths(core.GetIdentity(ctx), distributionName, cleanedPaths); len(deniedPaths) > 0 {
+		return nil, NewDenyError(distributionName, rule, deniedPaths)
+	}
 
-		if strings.HasPrefix(cleanedPath, distribution.Prefix) {
-			cleanedPaths = append(cleanedPaths, cleanedPath)
-		} else {
-			invalidPaths = append(invalidPaths, p)
+	invalidPaths := make([]string, 0)
+	for i, cleanedPath := range cleanedPaths {
+		if !distribution.Covers(cleanedPath) {
+			invalidPaths = append(invalidPaths, paths[i])
 		}
 	}
 	if len(invalidPaths) > 0 {
 		return nil, NewInvalidationError(BadRequestErrorCode, errors.New("unauthorized paths"), fmt.Sprintf("unauthorized paths: %v", invalidPaths))
 	}
 
+	// check the paths are within the sub-prefixes the user is granted
+	if _, deniedPaths := grants.PathsAllowed(cleanedPaths); len(deniedPaths) > 0 {
+		return nil, NewPathGrantError(distributionName, deniedPaths, grants.GrantedPaths())
+	}
+
+	// policies allow or deny the request as a whole
+	policy, err := snapshot.EvaluatePolicies(config.PolicyRequest{
+		Identity:         core.GetIdentity(ctx),
+		DistributionName: distributionName,
+		Distribution:     distribution,
+		Paths:            cleanedPaths,
+		Now:              d.now(),
+	})
+	if err != nil {
+		log.WithError(err).Error("policy evaluation failed")
+		return nil, NewPolicyError(distributionName, policy.Name, "policy evaluation failed")
+	}
+	if policy != nil {
+		return nil, NewPolicyError(distributionName, policy.Name, policy.Message)
+	}
+
 	res, err := d.Cloudfront.CreateInvalidation(ctx, distribution.ID, cleanedPaths)
 	if err != nil {
 		return nil, NewInvalidationError(BadRequestErrorCode, errors.New("cloudfront CreateInvalidation failed"), err)
 	}
 
+	log.WithFields(log.Fields{
+		"identity":       core.GetIdentity(ctx).Name(),
+		"distribution":   distributionName,
+		"invalidationID": res.InvalidationID,
+		"paths":          cleanedPaths,
+	}).Info("invalidation created")
+
 	return &InvalidationResponse{
 		InvalidationMeta: InvalidationMeta{
 			Status: res.Status,
@@ -100,7 +160,7 @@ func (d *DistributionService) CreateInvalidation(ctx context.Context, distributi
 }
 
 func (d *DistributionService) GetInvalidationStatus(ctx context.Context, distributionName string, invalidationID string) (*InvalidationResponse, error) {
-	distribution, err := d.getDistribution(ctx, distributionName)
+	distribution, _, err := d.getDistribution(ctx, d.Config.Snapshot(), distributionName, config.PermissionReadInvalidation)
 	if err != nil {
 		return nil, err
 	}
@@ -119,3 +179,92 @@ func (d *DistributionService) GetInvalidationStatus(ctx context.Context, distrib
 		Paths:   res.Paths,
 	}, nil
 }
+
+// ListGrants returns the temporary grants issued on the distribution
+func (d *DistributionService) ListGrants(ctx context.Context, distributionName string) ([]config.TemporaryGrant, error) {
+	if _, _, err := d.getDistribution(ctx, d.Config.Snapshot(), distributionName, config.PermissionManageDistribution); err != nil {
+		return nil, err
+	}
+
+	grants, err := d.Config.TemporaryGrants(distributionName)
+	if err != nil {
+		return nil, grantError(err, "")
+	}
+
+	return grants, nil
+}
+
+// CreateGrant issues a temporary grant on the distribution.  The grant may
+// not exceed the role, paths and time window the issuer manages the
+// distribution with, so that a temporary admin cannot renew their own access.
+func (d *DistributionService) CreateGrant(ctx context.Context, distributionName string, req GrantRequest) (*config.TemporaryGrant, error) {
+	distribution, grants, err := d.getDistribution(ctx, d.Config.Snapshot(), distributionName, config.PermissionManageDistribution)
+	if err != nil {
+		return nil, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = config.DefaultRole
+	}
+	if !grants.AllowsRole(role) {
+		return nil, NewInvalidationError(InvalidationUnauthorizedErrorCode, fmt.Errorf("role %s exceeds the role of the issuer", role), distributionName)
+	}
+
+	if grantedPaths := grants.GrantedPaths(); grantedPaths != nil {
+		// a grant without paths would cover the whole distribution
+		if len(req.Paths) == 0 {
+			return nil, NewPathGrantError(distributionName, distribution.PathPrefixes(), grantedPaths)
+		}
+		if _, denied := grants.PathsAllowed(req.Paths); len(denied) > 0 {
+			return nil, NewPathGrantError(distributionName, denied, grantedPaths)
+		}
+	}
+
+	notBefore, expires := grants.Window()
+	if expires != nil && req.Expires.After(*expires) {
+		return nil, NewInvalidationError(InvalidationUnauthorizedErrorCode, fmt.Errorf("expires %s exceeds the expiry %s of the issuer", req.Expires.Format(time.RFC3339), expires.Format(time.RFC3339)), distributionName)
+	}
+	if notBefore != nil && req.NotBefore != nil && req.NotBefore.Before(*notBefore) {
+		return nil, NewInvalidationError(InvalidationUnauthorizedErrorCode, fmt.Errorf("notBefore %s precedes the start %s of the issuer", req.NotBefore.Format(time.RFC3339), notBefore.Format(time.RFC3339)), distributionName)
+	}
+
+	grant, err := d.Config.AddTemporaryGrant(config.TemporaryGrant{
+		Holder:       req.Holder,
+		Distribution: distributionName,
+		Role:         req.Role,
+		Paths:        req.Paths,
+		NotBefore:    req.NotBefore,
+		Expires:      req.Expires,
+		CreatedBy:    core.GetIdentity(ctx).Name(),
+	})
+	if err != nil {
+		return nil, grantError(err, "")
+	}
+
+	return &grant, nil
+}
+
+// DeleteGrant revokes a temporary grant on the distribution
+func (d *DistributionService) DeleteGrant(ctx context.Context, distributionName string, grantID string) error {
+	if _, _, err := d.getDistribution(ctx, d.Config.Snapshot(), distributionName, config.PermissionManageDistribution); err != nil {
+		return err
+	}
+
+	if err := d.Config.DeleteTemporaryGrant(distributionName, grantID); err != nil {
+		return grantError(err, grantID)
+	}
+
+	return nil
+}
+
+func grantError(err error, grantID string) error {
+	switch {
+	case errors.Is(err, config.ErrGrantNotFound):
+		return NewInvalidationError(ResourceNotFoundErrorCode, err, fmt.Sprintf("grant %s", grantID))
+	case errors.Is(err, config.ErrNoGrantStore), errors.Is(err, config.ErrInvalidGrant):
+		return NewInvalidationError(BadRequestErrorCode, err, err.Error())
+	default:
+		return err
+	}
+}
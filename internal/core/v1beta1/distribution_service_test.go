@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -14,10 +16,10 @@ import (
 )
 
 func addClaims(ctx context.Context, claims []string) context.Context {
-	return context.WithValue(ctx, core.ContextBoundaryKey, claims)
+	return core.WithIdentity(ctx, &core.Identity{Claims: claims})
 }
 
-func newTestConfig() (*config.Config, error) {
+func newTestConfig(opts ...config.Option) (*config.Config, error) {
 	configYaml := `---
 distributions:
   dis1:
@@ -26,14 +28,84 @@ distributions:
   dis2:
     id: "456"
     prefix: "/bar"
+  team-a-site:
+    id: "123"
+    prefixes:
+      - "/assets/team-a"
+      - "/docs/team-a"
+    description: Site of team a
+    owners:
+      - team-a
+    contact: "#team-a"
+    links:
+      runbook: https://wiki.example.com/team-a/runbook
+    labels:
+      tier: "1"
+  team-a-localized:
+    id: "789"
+    patterns:
+      - glob: /*/team-a
 entitlements:
   grp1:
     - dis1
     - dis2
   grp2:
     - dis2
+  support:
+    - distribution: dis1
+      role: viewer
+  api-team:
+    - distribution: dis1
+      paths:
+        - /foo/api
+    - distribution: dis1
+      role: viewer
+      paths:
+        - /foo/guides
+  guides-team:
+    - distribution: dis1
+      paths:
+        - /foo/guides
+        - /foo/shared
+  contractors:
+    - dis1
+  batch:
+    - dis2
+  admins:
+    - distribution: dis1
+      role: admin
+  api-admins:
+    - distribution: dis1
+      role: admin
+      paths:
+        - /foo/api
+  team-a:
+    - team-a-site
+    - team-a-localized
+users:
+  email:
+    oncall@example.com:
+      - dis1
+deny:
+  - name: foo-legal
+    claims:
+      - contractors
+    distributions:
+      - dis1
+    paths:
+      - /foo/legal/*
+policies:
+  - name: batch-size
+    claims:
+      - batch
+    expression: size(paths) <= 2
+    message: at most 2 paths
+  - name: batch-hours
+    claims:
+      - batch
+    expression: now.getHours("UTC") >= 9 && now.getHours("UTC") < 17
 `
-	return config.NewTestConfigWithYaml([]byte(configYaml))
+	return config.NewTestConfigWithYaml([]byte(configYaml), opts...)
 }
 
 func TestGetDistribution(t *testing.T) {
@@ -78,7 +150,7 @@ func TestGetDistribution(t *testing.T) {
 	for _, test := range tests {
 		ctx := addClaims(context.Background(), test.claims)
 
-		ret, err := ds.getDistribution(ctx, test.distributionName)
+		ret, _, err := ds.getDistribution(ctx, ds.Config.Snapshot(), test.distributionName, config.PermissionCreateInvalidation)
 		if test.err != nil {
 			assert.Equal(t, test.err, err)
 		} else {
@@ -86,6 +158,25 @@ func TestGetDistribution(t *testing.T) {
 			assert.Equal(t, test.want, ret)
 		}
 	}
+
+	// user entitlement without any claims
+	ctx := core.WithIdentity(context.Background(), &core.Identity{Email: "oncall@example.com"})
+	ret, _, err := ds.getDistribution(ctx, ds.Config.Snapshot(), "dis1", config.PermissionCreateInvalidation)
+	assert.NoError(t, err)
+	assert.Equal(t, &config.Distribution{ID: "123", Prefix: "/foo"}, ret)
+
+	_, _, err = ds.getDistribution(ctx, ds.Config.Snapshot(), "dis2", config.PermissionCreateInvalidation)
+	assert.Equal(t, NewInvalidationError(InvalidationUnauthorizedErrorCode, errors.New("distribution unauthorized"), "dis2"), err)
+
+	// viewer role may read but not create invalidations
+	ctx = addClaims(context.Background(), []string{"support"})
+	_, grants, err := ds.getDistribution(ctx, ds.Config.Snapshot(), "dis1", config.PermissionReadInvalidation)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Grants{{Distribution: "dis1", Role: config.RoleViewer}}, grants)
+
+	_, _, err = ds.getDistribution(ctx, ds.Config.Snapshot(), "dis1", config.PermissionCreateInvalidation)
+	assert.Equal(t, NewPermissionError("dis1", config.PermissionCreateInvalidation), err)
+	assert.True(t, ErrorIsUnauthorized(err))
 }
 
 func TestList(t *testing.T) {
@@ -100,31 +191,53 @@ func TestList(t *testing.T) {
 		// inputs
 		claims []string
 		// outputs
-		want []string
+		want map[VanityDistributionName]Distribution
 		err  error
 	}{
 		{
 			// success
 			claims: []string{"grp1"},
-			want:   []string{"dis1", "dis2"},
-			err:    nil,
+			want: map[VanityDistributionName]Distribution{
+				"dis1": {DistributionID: "123", PathPrefix: "/foo", PathPrefixes: []string{"/foo"}},
+				"dis2": {DistributionID: "456", PathPrefix: "/bar", PathPrefixes: []string{"/bar"}},
+			},
+			err: nil,
 		},
 		{
 			// success
 			claims: []string{"grp2"},
-			want:   []string{"dis2"},
-			err:    nil,
+			want: map[VanityDistributionName]Distribution{
+				"dis2": {DistributionID: "456", PathPrefix: "/bar", PathPrefixes: []string{"/bar"}},
+			},
+			err: nil,
+		},
+		{
+			// success, multiple prefixes
+			claims: []string{"team-a"},
+			want: map[VanityDistributionName]Distribution{
+				"team-a-site": {
+					DistributionID: "123",
+					PathPrefixes:   []string{"/assets/team-a", "/docs/team-a"},
+					Description:    "Site of team a",
+					Owners:         []string{"team-a"},
+					Contact:        "#team-a",
+					Links:          map[string]string{"runbook": "https://wiki.example.com/team-a/runbook"},
+					Labels:         map[string]string{"tier": "1"},
+				},
+				"team-a-localized": {DistributionID: "789", PathPatterns: testConfig.Distribution("team-a-localized").Patterns},
+			},
+			err: nil,
 		},
 		{
 			// empty claims
 			claims: []string{},
-			want:   []string{},
+			want:   map[VanityDistributionName]Distribution{},
 			err:    errors.New("no claims present"),
 		},
 		{
 			// claim doesn't exist in entitlement but expect back empty list
 			claims: []string{"grp3"},
-			want:   []string{},
+			want:   map[VanityDistributionName]Distribution{},
 			err:    nil,
 		},
 	}
@@ -137,11 +250,60 @@ func TestList(t *testing.T) {
 			assert.Equal(t, test.err, err)
 		} else {
 			assert.NoError(t, err)
-			assert.ElementsMatch(t, test.want, ret)
+			assert.Equal(t, test.want, ret)
 		}
 	}
 }
 
+func TestNewDistributionsResponse(t *testing.T) {
+	details := map[VanityDistributionName]Distribution{
+		"dis2": {DistributionID: "456", PathPrefix: "/bar", PathPrefixes: []string{"/bar"}},
+		"dis1": {DistributionID: "123", PathPrefix: "/foo", PathPrefixes: []string{"/foo"}},
+	}
+
+	// the names stay a sorted list, the details are keyed by name
+	assert.Equal(t, DistributionsResponse{Distributions: []VanityDistributionName{"dis1", "dis2"}, Details: details}, NewDistributionsResponse(details))
+}
+
+func TestListDiscovered(t *testing.T) {
+	// discovered distributions are merged with the configuration loaded next
+	testConfig := config.New()
+	assert.NoError(t, testConfig.SetDiscoveredDistributions(map[string]*config.Distribution{
+		"sandbox": {ID: "E1", Prefixes: []string{"/my/path"}},
+	}))
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("entitlements:\n  grp1:\n    - sandbox\n"), 0o600))
+	assert.NoError(t, testConfig.Watch(file))
+
+	ds := New(testConfig, cloudfront.NewTestCloudfrontClient(&cloudfront.MockCloudFrontClient{}))
+
+	ret, err := ds.List(addClaims(context.Background(), []string{"grp1"}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[VanityDistributionName]Distribution{
+		"sandbox": {DistributionID: "E1", PathPrefix: "/my/path", PathPrefixes: []string{"/my/path"}, Discovered: true},
+	}, ret)
+}
+
+func TestConfigInfo(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("distributions:\n  dis1:\n    id: \"123\"\n    prefix: /foo\n"), 0o600))
+
+	testConfig := config.New()
+	assert.NoError(t, testConfig.Watch(file))
+
+	ds := New(testConfig, cloudfront.NewTestCloudfrontClient(&cloudfront.MockCloudFrontClient{}))
+
+	_, err := ds.ConfigInfo(addClaims(context.Background(), []string{}))
+	assert.Equal(t, errors.New("no claims present"), err)
+
+	info, err := ds.ConfigInfo(addClaims(context.Background(), []string{"grp1"}))
+	assert.NoError(t, err)
+	assert.Equal(t, file, info.Source)
+	assert.Len(t, info.Hash, 64)
+	assert.False(t, info.LoadedAt.IsZero())
+	assert.Empty(t, info.LastReloadError)
+}
+
 func TestCreateInvalidation(t *testing.T) {
 	testConfig, err := newTestConfig()
 	assert.NoError(t, err)
@@ -186,6 +348,71 @@ func TestCreateInvalidation(t *testing.T) {
 			want:             nil,
 			err:              NewInvalidationError(BadRequestErrorCode, errors.New("unauthorized paths"), fmt.Sprintf("unauthorized paths: %v", []string{"/a/*", "/a/../*", "..", "/foo/../*", "/foo/a/..//../*"})),
 		},
+		{
+			// success, paths within any of the prefixes
+			claims:           []string{"team-a"},
+			distributionName: "team-a-site",
+			paths:            []string{"/assets/team-a/*", "/docs/team-a/index.html"},
+			mockCf: &cloudfront.MockCloudFrontClient{
+				CreateTime:     time.Unix(0, 0).UTC(),
+				InvalidationId: "ABC123",
+				Status:         "In Progress",
+			},
+			want: &InvalidationResponse{
+				InvalidationMeta: InvalidationMeta{
+					Status: "In Progress",
+				},
+				ID:      "ABC123",
+				Created: time.Unix(0, 0).UTC(),
+				Paths:   []string{"/assets/team-a/*", "/docs/team-a/index.html"},
+			},
+		},
+		{
+			// error, paths outside of all prefixes
+			claims:           []string{"team-a"},
+			distributionName: "team-a-site",
+			paths:            []string{"/assets/team-a/*", "/assets/team-b/*", "/docs/*"},
+			mockCf:           &cloudfront.MockCloudFrontClient{},
+			want:             nil,
+			err:              NewInvalidationError(BadRequestErrorCode, errors.New("unauthorized paths"), fmt.Sprintf("unauthorized paths: %v", []string{"/assets/team-b/*", "/docs/*"})),
+		},
+		{
+			// success, paths matching a pattern
+			claims:           []string{"team-a"},
+			distributionName: "team-a-localized",
+			paths:            []string{"/en/team-a/*", "/de/team-a/index.html"},
+			mockCf: &cloudfront.MockCloudFrontClient{
+				CreateTime:     time.Unix(0, 0).UTC(),
+				InvalidationId: "ABC123",
+				Status:         "In Progress",
+			},
+			want: &InvalidationResponse{
+				InvalidationMeta: InvalidationMeta{
+					Status: "In Progress",
+				},
+				ID:      "ABC123",
+				Created: time.Unix(0, 0).UTC(),
+				Paths:   []string{"/en/team-a/*", "/de/team-a/index.html"},
+			},
+		},
+		{
+			// error, paths not matching a pattern
+			claims:           []string{"team-a"},
+			distributionName: "team-a-localized",
+			paths:            []string{"/en/team-a/*", "/en/*", "/en/team-b/index.html"},
+			mockCf:           &cloudfront.MockCloudFrontClient{},
+			want:             nil,
+			err:              NewInvalidationError(BadRequestErrorCode, errors.New("unauthorized paths"), fmt.Sprintf("unauthorized paths: %v", []string{"/en/*", "/en/team-b/index.html"})),
+		},
+		{
+			// error, paths sharing the prefix but not its segment
+			claims:           []string{"grp1"},
+			distributionName: "dis1",
+			paths:            []string{"/foo", "/foobar/*", "/foo*"},
+			mockCf:           &cloudfront.MockCloudFrontClient{},
+			want:             nil,
+			err:              NewInvalidationError(BadRequestErrorCode, errors.New("unauthorized paths"), fmt.Sprintf("unauthorized paths: %v", []string{"/foobar/*", "/foo*"})),
+		},
 		{
 			// error from cloudfront api
 			claims:           []string{"grp2"},
@@ -195,6 +422,52 @@ func TestCreateInvalidation(t *testing.T) {
 			want:             nil,
 			err:              NewInvalidationError(BadRequestErrorCode, errors.New("cloudfront CreateInvalidation failed"), errors.New("mock cloudfront error")),
 		},
+		{
+			// success, paths within granted sub-prefixes
+			claims:           []string{"api-team", "guides-team"},
+			distributionName: "dis1",
+			paths:            []string{"/foo/api/*", "/foo/guides/index.html", "/foo/shared"},
+			mockCf:           &cloudfront.MockCloudFrontClient{Status: "In Progress"},
+			want: &InvalidationResponse{
+				InvalidationMeta: InvalidationMeta{
+					Status: "In Progress",
+				},
+				Paths: []string{"/foo/api/*", "/foo/guides/index.html", "/foo/shared"},
+			},
+			err: nil,
+		},
+		{
+			// error, paths outside of granted sub-prefixes, the viewer grant does not allow creating
+			claims:           []string{"api-team"},
+			distributionName: "dis1",
+			paths:            []string{"/foo/api/*", "/foo/guides/*", "/foo/apiv2/*", "/foo/api*", "/foo/*"},
+			mockCf:           &cloudfront.MockCloudFrontClient{},
+			want:             nil,
+			err:              NewPathGrantError("dis1", []string{"/foo/guides/*", "/foo/apiv2/*", "/foo/api*", "/foo/*"}, []string{"/foo/api"}),
+		},
+		{
+			// error, deny rules are evaluated on cleaned paths before the prefix check
+			claims:           []string{"contractors"},
+			distributionName: "dis1",
+			paths:            []string{"/foo/public/*", "/foo/x/../legal/terms.html", "/foo/*", "/bar/*"},
+			mockCf:           &cloudfront.MockCloudFrontClient{},
+			want:             nil,
+			err:              NewDenyError("dis1", "foo-legal", []string{"/foo/legal/terms.html", "/foo/*"}),
+		},
+		{
+			// success, paths outside of the deny rule
+			claims:           []string{"contractors"},
+			distributionName: "dis1",
+			paths:            []string{"/foo/public/*", "/foo/legally.html"},
+			mockCf:           &cloudfront.MockCloudFrontClient{Status: "In Progress"},
+			want: &InvalidationResponse{
+				InvalidationMeta: InvalidationMeta{
+					Status: "In Progress",
+				},
+				Paths: []string{"/foo/public/*", "/foo/legally.html"},
+			},
+			err: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -213,6 +486,128 @@ func TestCreateInvalidation(t *testing.T) {
 	}
 }
 
+func TestCreateInvalidationPolicies(t *testing.T) {
+	testConfig, err := newTestConfig()
+	assert.NoError(t, err)
+
+	ds := New(testConfig, cloudfront.NewTestCloudfrontClient(&cloudfront.MockCloudFrontClient{Status: "In Progress"}))
+	ctx := addClaims(context.Background(), []string{"batch"})
+
+	ds.now = func() time.Time { return time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) }
+
+	_, err = ds.CreateInvalidation(ctx, "dis2", []string{"/bar/a", "/bar/b"})
+	assert.NoError(t, err)
+
+	_, err = ds.CreateInvalidation(ctx, "dis2", []string{"/bar/a", "/bar/b", "/bar/c"})
+	assert.Equal(t, NewPolicyError("dis2", "batch-size", "at most 2 paths"), err)
+	assert.True(t, ErrorIsUnauthorized(err))
+
+	ds.now = func() time.Time { return time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC) }
+
+	_, err = ds.CreateInvalidation(ctx, "dis2", []string{"/bar/a"})
+	assert.Equal(t, NewPolicyError("dis2", "batch-hours", ""), err)
+}
+
+func TestGrants(t *testing.T) {
+	store, err := config.NewGrantStore(filepath.Join(t.TempDir(), "grants.json"))
+	assert.NoError(t, err)
+
+	testConfig, err := newTestConfig(config.WithGrantStore(store))
+	assert.NoError(t, err)
+
+	ds := New(testConfig, cloudfront.NewTestCloudfrontClient(&cloudfront.MockCloudFrontClient{}))
+	admin := core.WithIdentity(context.Background(), &core.Identity{PreferredUsername: "admin", Claims: []string{"admins"}})
+	oncall := addClaims(context.Background(), []string{"oncall"})
+
+	req := GrantRequest{
+		Holder:  config.GrantHolder{Type: config.HolderClaim, Name: "oncall"},
+		Paths:   []string{"/foo/api"},
+		Expires: time.Now().Add(time.Hour),
+	}
+
+	// only admins may manage grants
+	_, err = ds.CreateGrant(addClaims(context.Background(), []string{"grp1"}), "dis1", req)
+	assert.Equal(t, NewPermissionError("dis1", config.PermissionManageDistribution), err)
+
+	_, err = ds.CreateGrant(admin, "dis2", req)
+	assert.Equal(t, NewInvalidationError(InvalidationUnauthorizedErrorCode, errors.New("distribution unauthorized"), "dis2"), err)
+
+	_, err = ds.CreateGrant(admin, "dis1", GrantRequest{Holder: req.Holder})
+	assert.True(t, ErrorBadRequest(err))
+
+	_, err = ds.List(oncall)
+	assert.NoError(t, err)
+
+	grant, err := ds.CreateGrant(admin, "dis1", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", grant.CreatedBy)
+	assert.Equal(t, config.RoleInvalidator, grant.Role)
+
+	// the grant takes effect immediately
+	list, err := ds.List(oncall)
+	assert.NoError(t, err)
+	assert.Contains(t, list, VanityDistributionName("dis1"))
+	assert.Len(t, list, 1)
+
+	_, err = ds.CreateInvalidation(oncall, "dis1", []string{"/foo/api/*"})
+	assert.NoError(t, err)
+
+	grants, err := ds.ListGrants(admin, "dis1")
+	assert.NoError(t, err)
+	assert.Equal(t, []config.TemporaryGrant{*grant}, grants)
+
+	// path-scoped admins may only grant within their paths
+	apiAdmin := addClaims(context.Background(), []string{"api-admins"})
+	_, err = ds.CreateGrant(apiAdmin, "dis1", GrantRequest{Holder: req.Holder, Paths: []string{"/foo/api/v2"}, Expires: req.Expires})
+	assert.NoError(t, err)
+
+	_, err = ds.CreateGrant(apiAdmin, "dis1", GrantRequest{Holder: req.Holder, Paths: []string{"/foo/guides"}, Expires: req.Expires})
+	assert.Equal(t, NewPathGrantError("dis1", []string{"/foo/guides"}, []string{"/foo/api"}), err)
+
+	_, err = ds.CreateGrant(apiAdmin, "dis1", GrantRequest{Holder: req.Holder, Expires: req.Expires})
+	assert.Equal(t, NewPathGrantError("dis1", []string{"/foo"}, []string{"/foo/api"}), err)
+	assert.True(t, ErrorIsUnauthorized(err))
+
+	grants, err = ds.ListGrants(admin, "dis1")
+	assert.NoError(t, err)
+	assert.Len(t, grants, 2)
+	assert.NoError(t, ds.DeleteGrant(admin, "dis1", grants[1].ID))
+
+	assert.NoError(t, ds.DeleteGrant(admin, "dis1", grant.ID))
+	assert.True(t, ErrorResourceNotFound(ds.DeleteGrant(admin, "dis1", grant.ID)))
+
+	list, err = ds.List(oncall)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	// temporary admins may not extend their own access
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	end := time.Now().Add(time.Hour).Truncate(time.Second)
+	breakGlass := config.GrantHolder{Type: config.HolderClaim, Name: "break-glass"}
+	_, err = ds.CreateGrant(admin, "dis1", GrantRequest{Holder: breakGlass, Role: config.RoleAdmin, NotBefore: &start, Expires: end})
+	assert.NoError(t, err)
+
+	temporaryAdmin := addClaims(context.Background(), []string{"break-glass"})
+	_, err = ds.CreateGrant(temporaryAdmin, "dis1", GrantRequest{Holder: breakGlass, Role: config.RoleAdmin, Expires: end.Add(time.Hour)})
+	assert.EqualError(t, err, fmt.Sprintf("expires %s exceeds the expiry %s of the issuer", end.Add(time.Hour).Format(time.RFC3339), end.Format(time.RFC3339)))
+	assert.True(t, ErrorIsUnauthorized(err))
+
+	earlier := start.Add(-time.Hour)
+	_, err = ds.CreateGrant(temporaryAdmin, "dis1", GrantRequest{Holder: breakGlass, NotBefore: &earlier, Expires: end})
+	assert.EqualError(t, err, fmt.Sprintf("notBefore %s precedes the start %s of the issuer", earlier.Format(time.RFC3339), start.Format(time.RFC3339)))
+	assert.True(t, ErrorIsUnauthorized(err))
+
+	_, err = ds.CreateGrant(temporaryAdmin, "dis1", GrantRequest{Holder: req.Holder, Expires: end})
+	assert.NoError(t, err)
+
+	// temporary grants require a grant store
+	testConfig, err = newTestConfig()
+	assert.NoError(t, err)
+
+	_, err = New(testConfig, nil).ListGrants(admin, "dis1")
+	assert.Equal(t, NewInvalidationError(BadRequestErrorCode, config.ErrNoGrantStore, config.ErrNoGrantStore.Error()), err)
+}
+
 func TestGetInvalidationStatus(t *testing.T) {
 	testConfig, err := newTestConfig()
 	assert.NoError(t, err)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/kanopy-platform/cdnvalidator/internal/config"
 	"github.com/kanopy-platform/cdnvalidator/internal/core"
 )
 
@@ -17,24 +18,36 @@ func NewFake() *Fake {
 
 }
 
-func (f *Fake) List(ctx context.Context) ([]string, error) {
-	claims := core.GetClaims(ctx)
-	if len(claims) == 0 {
+func (f *Fake) List(ctx context.Context) (map[VanityDistributionName]Distribution, error) {
+	identity := core.GetIdentity(ctx)
+	if identity.IsEmpty() {
 		return nil, errors.New("no claims present")
 	}
 
-	if claims[0] == "gr1" {
-		return []string{
-			"f1",
+	if len(identity.Claims) > 0 && identity.Claims[0] == "gr1" {
+		return map[VanityDistributionName]Distribution{
+			"f1": {DistributionID: "F1", PathPrefix: "/f1", PathPrefixes: []string{"/f1"}, Description: "Fake distribution", Owners: []string{"team-f"}, Contact: "#team-f"},
 		}, nil
 	}
 
 	return nil, nil
 }
 
+func (f *Fake) ConfigInfo(ctx context.Context) (*ConfigInfoResponse, error) {
+	if core.GetIdentity(ctx).IsEmpty() {
+		return nil, errors.New("no claims present")
+	}
+
+	return &ConfigInfoResponse{Info: config.Info{
+		Hash:            "abc123",
+		LoadedAt:        time.Unix(0, 0).UTC(),
+		Source:          "/etc/cdnvalidator/config.yaml",
+		LastReloadError: "error parsing configuration: unknown key polices at line 1",
+	}}, nil
+}
+
 func (f *Fake) CreateInvalidation(ctx context.Context, distributionName string, paths []string) (*InvalidationResponse, error) {
-	claims := core.GetClaims(ctx)
-	if len(claims) == 0 {
+	if core.GetIdentity(ctx).IsEmpty() {
 		return nil, errors.New("no claims present")
 	}
 
@@ -42,12 +55,15 @@ func (f *Fake) CreateInvalidation(ctx context.Context, distributionName string,
 		return nil, err
 	}
 
+	if distributionName == "viewer distribution" {
+		return nil, NewPermissionError(distributionName, config.PermissionCreateInvalidation)
+	}
+
 	return &InvalidationResponse{InvalidationMeta: InvalidationMeta{Status: "OK"}}, nil
 }
 
 func (f *Fake) GetInvalidationStatus(ctx context.Context, distributionName string, invalidationID string) (*InvalidationResponse, error) {
-	claims := core.GetClaims(ctx)
-	if len(claims) == 0 {
+	if core.GetIdentity(ctx).IsEmpty() {
 		return nil, errors.New("no claims present")
 	}
 
@@ -62,6 +78,48 @@ func (f *Fake) GetInvalidationStatus(ctx context.Context, distributionName strin
 	}, nil
 }
 
+func (f *Fake) ListGrants(ctx context.Context, distributionName string) ([]config.TemporaryGrant, error) {
+	if err := checkErrors(distributionName, ""); err != nil {
+		return nil, err
+	}
+
+	return []config.TemporaryGrant{{ID: "1", Distribution: distributionName}}, nil
+}
+
+func (f *Fake) CreateGrant(ctx context.Context, distributionName string, req GrantRequest) (*config.TemporaryGrant, error) {
+	if err := checkErrors(distributionName, ""); err != nil {
+		return nil, err
+	}
+
+	if distributionName == "viewer distribution" {
+		return nil, NewPermissionError(distributionName, config.PermissionManageDistribution)
+	}
+
+	if req.Holder.Name == "" {
+		return nil, NewInvalidationError(BadRequestErrorCode, config.ErrInvalidGrant, "holder name must not be empty")
+	}
+
+	return &config.TemporaryGrant{
+		ID:           "1",
+		Holder:       req.Holder,
+		Distribution: distributionName,
+		Role:         req.Role,
+		Expires:      req.Expires,
+	}, nil
+}
+
+func (f *Fake) DeleteGrant(ctx context.Context, distributionName string, grantID string) error {
+	if err := checkErrors(distributionName, ""); err != nil {
+		return err
+	}
+
+	if grantID == "notfound" {
+		return NewInvalidationError(ResourceNotFoundErrorCode, config.ErrGrantNotFound, "grant notfound")
+	}
+
+	return nil
+}
+
 func checkErrors(distributionName, invalidationID string) error {
 	if distributionName == "notfound" {
 		return NewInvalidationError(ResourceNotFoundErrorCode, fmt.Errorf("distribution %s not found", distributionName), distributionName)
@@ -3,14 +3,55 @@ package v1beta1
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/config"
 )
 
 type VanityDistributionName string
 
 type Distribution struct {
 	DistributionID string `json:"-"`
-	PathPrefix     string `json:"pathPrefix"`
+	// The PathPrefix of a distribution with a single prefix
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// The PathPrefixes invalidations of the distribution must lie within
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+	// The PathPatterns invalidations of the distribution may match instead of a prefix
+	PathPatterns []config.PathPattern `json:"pathPatterns,omitempty"`
+	// Discovered is true when the distribution was discovered from Cloudfront tags
+	Discovered bool `json:"discovered,omitempty"`
+	// The Description of what the distribution serves
+	Description string `json:"description,omitempty"`
+	// The Owners responsible for the distribution
+	Owners []string `json:"owners,omitempty"`
+	// The Contact of the owners, a Slack channel or email address
+	Contact string `json:"contact,omitempty"`
+	// The Links of the distribution by name, e.g. runbook or site
+	Links map[string]string `json:"links,omitempty"`
+	// The Labels of the distribution
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewDistribution describes the configured distribution to API clients
+func NewDistribution(distribution *config.Distribution) Distribution {
+	ret := Distribution{
+		DistributionID: distribution.ID,
+		PathPrefixes:   distribution.PathPrefixes(),
+		PathPatterns:   distribution.Patterns,
+		Discovered:     distribution.Discovered,
+		Description:    distribution.Description,
+		Owners:         distribution.Owners,
+		Contact:        distribution.Contact,
+		Links:          distribution.Links,
+		Labels:         distribution.Labels,
+	}
+
+	if len(ret.PathPrefixes) == 1 {
+		ret.PathPrefix = ret.PathPrefixes[0]
+	}
+
+	return ret
 }
 
 type InvalidationMeta struct {
@@ -34,7 +75,21 @@ type InvalidationResponse struct {
 // swagger:model DistributionResponse
 type DistributionsResponse struct {
 	// The Distributions a user is entitled to perform invalidations against.
-	Distributions map[VanityDistributionName]Distribution `json:"distributions"`
+	Distributions []VanityDistributionName `json:"distributions"`
+	// The Details of the distributions, such as their paths and metadata, by name
+	Details map[VanityDistributionName]Distribution `json:"details"`
+}
+
+// NewDistributionsResponse lists the names of the distributions sorted, along
+// with their details
+func NewDistributionsResponse(distributions map[VanityDistributionName]Distribution) DistributionsResponse {
+	names := make([]VanityDistributionName, 0, len(distributions))
+	for name := range distributions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return DistributionsResponse{Distributions: names, Details: distributions}
 }
 
 // swagger:model InvalidationRequest
@@ -43,6 +98,32 @@ type InvalidationRequest struct {
 	Paths []string `json:"paths"`
 }
 
+// swagger:model GrantRequest
+type GrantRequest struct {
+	// The Holder the grant is issued to
+	Holder config.GrantHolder `json:"holder"`
+	// The Role granted, defaults to invalidator
+	Role config.Role `json:"role,omitempty"`
+	// The Paths the grant is narrowed to, all paths of the distribution when empty
+	Paths []string `json:"paths,omitempty"`
+	// The NotBefore time the grant becomes active
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// The Expires time of the grant
+	Expires time.Time `json:"expires"`
+}
+
+// swagger:model GrantsResponse
+type GrantsResponse struct {
+	// The temporary Grants issued on the distribution
+	Grants []config.TemporaryGrant `json:"grants"`
+}
+
+// swagger:model ConfigInfoResponse
+type ConfigInfoResponse struct {
+	// The Info of the active configuration and of its last reload
+	config.Info
+}
+
 // swagger:parameters submit-invalidation
 type _ struct {
 	// The Name of the distribution
@@ -64,6 +145,34 @@ type _ struct {
 	ID string
 }
 
+// swagger:parameters list-grants
+type _ struct {
+	// The Name of the distribution
+	// in:path
+	Name string
+}
+
+// swagger:parameters create-grant
+type _ struct {
+	// The Name of the distribution
+	// in:path
+	Name string
+	// The temporary grant to issue
+	// in:body
+	// required: true
+	Body GrantRequest
+}
+
+// swagger:parameters delete-grant
+type _ struct {
+	// The Name of the distribution
+	// in:path
+	Name string
+	// The ID of the grant
+	// in:path
+	ID string
+}
+
 const (
 	BadRequestErrorCode               = 400
 	ResourceNotFoundErrorCode         = 404
@@ -81,8 +190,14 @@ var (
 // swagger:model InvalidationError
 type InvalidationError struct {
 	InvalidationMeta
-	Err  error `json:"-"`
-	Code int   `json:"-"`
+	// The Permission the user is missing on the distribution
+	MissingPermission string `json:"missingPermission,omitempty"`
+	// The Paths denied by a deny rule
+	DeniedPaths []string `json:"deniedPaths,omitempty"`
+	// The deny Rule or Policy denying the request
+	Rule string `json:"rule,omitempty"`
+	Err  error  `json:"-"`
+	Code int    `json:"-"`
 }
 
 // swagger:model ErrorResponse
@@ -109,6 +224,59 @@ func NewInvalidationError(code int, err error, args ...interface{}) error {
 	}
 }
 
+// NewPermissionError reports that the role of the user on the distribution does not grant permission
+func NewPermissionError(distributionName string, permission config.Permission) error {
+	return InvalidationError{
+		Code: InvalidationUnauthorizedErrorCode,
+		InvalidationMeta: InvalidationMeta{
+			Status: fmt.Sprintf("User is missing permission %s on distribution: %s", permission, distributionName),
+		},
+		MissingPermission: string(permission),
+		Err:               fmt.Errorf("missing permission %s", permission),
+	}
+}
+
+// NewPathGrantError reports paths of the distribution that are outside of the sub-prefixes the user is granted
+func NewPathGrantError(distributionName string, paths []string, grantedPaths []string) error {
+	return InvalidationError{
+		Code: InvalidationUnauthorizedErrorCode,
+		InvalidationMeta: InvalidationMeta{
+			Status: fmt.Sprintf("User is missing a grant for paths %v on distribution: %s (granted paths: %v)", paths, distributionName, grantedPaths),
+		},
+		Err: fmt.Errorf("paths %v outside of granted paths %v", paths, grantedPaths),
+	}
+}
+
+// NewDenyError reports paths of the distribution forbidden by a deny rule
+func NewDenyError(distributionName string, rule string, paths []string) error {
+	return InvalidationError{
+		Code: InvalidationUnauthorizedErrorCode,
+		InvalidationMeta: InvalidationMeta{
+			Status: fmt.Sprintf("Paths %v on distribution: %s are denied by rule %s", paths, distributionName, rule),
+		},
+		DeniedPaths: paths,
+		Rule:        rule,
+		Err:         fmt.Errorf("paths %v denied by rule %s", paths, rule),
+	}
+}
+
+// NewPolicyError reports that a policy denied the invalidation request
+func NewPolicyError(distributionName string, policy string, message string) error {
+	status := fmt.Sprintf("Invalidation on distribution: %s is denied by policy %s", distributionName, policy)
+	if message != "" {
+		status = fmt.Sprintf("%s: %s", status, message)
+	}
+
+	return InvalidationError{
+		Code: InvalidationUnauthorizedErrorCode,
+		InvalidationMeta: InvalidationMeta{
+			Status: status,
+		},
+		Rule: policy,
+		Err:  fmt.Errorf("denied by policy %s", policy),
+	}
+}
+
 func ErrorBadRequest(err error) bool {
 	var ierr InvalidationError
 	if !errors.As(err, &ierr) {
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+)
+
+// attributeClaims are the token claims exposed as identity attributes
+var attributeClaims = []string{
+	"repository",
+	"repository_owner",
+	"ref",
+	"ref_type",
+	"environment",
+	"workflow",
+	"job_workflow_ref",
+	"event_name",
+	"actor",
+}
+
+// Authenticator authenticates GitHub Actions OIDC tokens, allowing
+// workflows to call the API without a stored secret
+type Authenticator struct {
+	issuer   string
+	verifier *jwt.Verifier
+}
+
+// New returns an Authenticator for tokens of issuer verified by verifier,
+// the verifier should require the expected audience
+func New(issuer string, verifier *jwt.Verifier) (*Authenticator, error) {
+	if issuer == "" {
+		return nil, errors.New("missing required parameter issuer")
+	}
+	if verifier == nil {
+		return nil, errors.New("missing required parameter verifier")
+	}
+
+	return &Authenticator{
+		issuer:   issuer,
+		verifier: verifier,
+	}, nil
+}
+
+// Issuer returns the iss claim of the tokens handled by the Authenticator
+func (a *Authenticator) Issuer() string {
+	return a.issuer
+}
+
+// AuthenticateToken verifies the token and returns the identity of the
+// workflow run with the repository, ref, environment and workflow claims
+// as attributes
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*core.Identity, error) {
+	claims, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	attributes := map[string]string{}
+	for _, name := range attributeClaims {
+		if values := claims.Values(name); len(values) == 1 {
+			attributes[name] = values[0]
+		}
+	}
+
+	if attributes["repository"] == "" {
+		return nil, errors.New("token has no repository claim")
+	}
+
+	return &core.Identity{
+		Subject:    claims.Subject,
+		Issuer:     a.issuer,
+		Attributes: attributes,
+	}, nil
+}
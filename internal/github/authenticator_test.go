@@ -0,0 +1,78 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	key, err := jwt.NewTestSigningKey("k1")
+	require.NoError(t, err)
+
+	server := jwt.NewTestOIDCServer(func() *jose.JSONWebKeySet { return jwt.NewTestKeySet(key) })
+	defer server.Close()
+
+	verifier, err := jwt.NewOIDCVerifier(context.Background(), server.URL, jwt.WithAudience("cdnvalidator"))
+	require.NoError(t, err)
+
+	_, err = New("", verifier)
+	assert.Error(t, err)
+	_, err = New(server.URL, nil)
+	assert.Error(t, err)
+
+	a, err := New(server.URL, verifier)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, a.Issuer())
+
+	newToken := func(aud string, extra map[string]interface{}) string {
+		claims := jwt.NewTestRegisteredClaims(server.URL, aud)
+		claims.Subject = "repo:org/site:environment:production"
+
+		token, err := jwt.NewTestSignedJWTWithClaims(key, claims, extra)
+		require.NoError(t, err)
+		return token
+	}
+
+	workflowClaims := map[string]interface{}{
+		"repository":       "org/site",
+		"repository_owner": "org",
+		"ref":              "refs/heads/main",
+		"ref_type":         "branch",
+		"environment":      "production",
+		"workflow":         "deploy",
+		"job_workflow_ref": "org/site/.github/workflows/deploy.yml@refs/heads/main",
+		"event_name":       "push",
+		"actor":            "octocat",
+		"run_id":           "42",
+	}
+
+	identity, err := a.AuthenticateToken(context.Background(), newToken("cdnvalidator", workflowClaims))
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Identity{
+		Subject: "repo:org/site:environment:production",
+		Issuer:  server.URL,
+		Attributes: map[string]string{
+			"repository":       "org/site",
+			"repository_owner": "org",
+			"ref":              "refs/heads/main",
+			"ref_type":         "branch",
+			"environment":      "production",
+			"workflow":         "deploy",
+			"job_workflow_ref": "org/site/.github/workflows/deploy.yml@refs/heads/main",
+			"event_name":       "push",
+			"actor":            "octocat",
+		},
+	}, identity)
+
+	_, err = a.AuthenticateToken(context.Background(), newToken("https://github.com/org", workflowClaims))
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+
+	_, err = a.AuthenticateToken(context.Background(), newToken("cdnvalidator", map[string]interface{}{"ref": "refs/heads/main"}))
+	assert.EqualError(t, err, "token has no repository claim")
+}
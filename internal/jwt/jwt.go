@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"strings"
+
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
@@ -8,6 +10,12 @@ type Claims struct {
 	jwt.Claims
 	Groups []string `json:"groups"`
 	Scopes []string `json:"scp"`
+	// Email is only trusted when email_verified is absent or true
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	// Raw holds every claim of the token
+	Raw map[string]interface{} `json:"-"`
 }
 
 func TokenClaims(rawToken string) (*Claims, error) {
@@ -17,9 +25,70 @@ func TokenClaims(rawToken string) (*Claims, error) {
 	}
 
 	c := &Claims{}
-	if err := token.UnsafeClaimsWithoutVerification(c); err != nil {
+	if err := token.UnsafeClaimsWithoutVerification(c, &c.Raw); err != nil {
 		return nil, err
 	}
 
 	return c, nil
 }
+
+// VerifiedEmail returns the email claim unless the token marks it unverified
+func (c *Claims) VerifiedEmail() string {
+	if c.EmailVerified != nil && !*c.EmailVerified {
+		return ""
+	}
+
+	return c.Email
+}
+
+// Values returns the string values of the claim found at path.  Nested claims
+// are addressed using dots, e.g. realm_access.roles, keys containing dots such
+// as namespaced claims are matched before descending.  String claims return a
+// single value, arrays return their string elements.
+func (c *Claims) Values(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	return claimValues(c.Raw, strings.Split(path, "."))
+}
+
+func claimValues(claims map[string]interface{}, segments []string) []string {
+	// prefer the longest key so that "https://example.com/groups" is found
+	// before descending into "https://example"
+	for i := len(segments); i > 0; i-- {
+		v, ok := claims[strings.Join(segments[:i], ".")]
+		if !ok {
+			continue
+		}
+
+		if i == len(segments) {
+			return stringValues(v)
+		}
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			if values := claimValues(nested, segments[i:]); values != nil {
+				return values
+			}
+		}
+	}
+
+	return nil
+}
+
+func stringValues(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,268 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestTokenClaims(t *testing.T) {
+	rawToken, err := NewTestJWTWithClaims(Claims{
+		Groups: []string{"g1"},
+		Scopes: []string{"s1"},
+	})
+	require.NoError(t, err)
+
+	c, err := TokenClaims(rawToken)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"g1"}, c.Groups)
+	assert.Equal(t, []string{"s1"}, c.Scopes)
+	assert.Equal(t, []string{"g1"}, c.Values("groups"))
+
+	_, err = TokenClaims("invalid")
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	key1, err := NewTestSigningKey("key1")
+	require.NoError(t, err)
+	key2, err := NewTestSigningKey("key2")
+	require.NoError(t, err)
+	unknown, err := NewTestSigningKey("key1")
+	require.NoError(t, err)
+
+	server := NewTestJWKSServer(func() *jose.JSONWebKeySet { return NewTestKeySet(key1, key2) })
+	defer server.Close()
+
+	v := NewVerifier(NewRemoteKeySet(server.URL))
+
+	claims := Claims{Claims: NewTestRegisteredClaims(""), Groups: []string{"g1"}}
+
+	signed1, err := NewTestSignedJWTWithClaims(key1, claims)
+	require.NoError(t, err)
+	signed2, err := NewTestSignedJWTWithClaims(key2, claims)
+	require.NoError(t, err)
+	wrongKey, err := NewTestSignedJWTWithClaims(unknown, claims)
+	require.NoError(t, err)
+	hmac, err := NewTestJWTWithClaims(claims)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "valid key1", token: signed1},
+		{name: "valid key2", token: signed2},
+		{name: "wrong key with known kid", token: wrongKey, err: ErrInvalidSignature},
+		{name: "symmetric algorithm", token: hmac, err: ErrUnsupportedAlgorithm},
+	}
+
+	for _, test := range tests {
+		c, err := v.Verify(context.Background(), test.token)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, []string{"g1"}, c.Groups, test.name)
+	}
+
+	// unsigned token
+	_, err = v.Verify(context.Background(), "eyJhbGciOiJub25lIn0.eyJncm91cHMiOlsiZzEiXX0.")
+	assert.Error(t, err)
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	oldKey, err := NewTestSigningKey("old")
+	require.NoError(t, err)
+	newKey, err := NewTestSigningKey("new")
+	require.NoError(t, err)
+
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	server := NewTestJWKSServer(func() *jose.JSONWebKeySet {
+		fetches.Add(1)
+		if rotated.Load() {
+			return NewTestKeySet(newKey)
+		}
+		return NewTestKeySet(oldKey)
+	})
+	defer server.Close()
+
+	keySet := NewRemoteKeySet(server.URL).(*keyCache)
+	keySet.minRefresh = 0
+	v := NewVerifier(keySet)
+
+	claims := Claims{Claims: NewTestRegisteredClaims(""), Groups: []string{"g1"}}
+
+	oldToken, err := NewTestSignedJWTWithClaims(oldKey, claims)
+	require.NoError(t, err)
+	newToken, err := NewTestSignedJWTWithClaims(newKey, claims)
+	require.NoError(t, err)
+
+	_, err = v.Verify(context.Background(), oldToken)
+	assert.NoError(t, err)
+	_, err = v.Verify(context.Background(), oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "keys are cached")
+
+	rotated.Store(true)
+
+	_, err = v.Verify(context.Background(), newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load(), "unknown kid refreshes the key set")
+
+	_, err = v.Verify(context.Background(), oldToken)
+	assert.ErrorIs(t, err, ErrNoMatchingKey)
+}
+
+func TestFileKeySet(t *testing.T) {
+	key, err := NewTestSigningKey("file")
+	require.NoError(t, err)
+
+	tmpFile, err := os.CreateTemp(os.TempDir(), "cdnvalidator-jwks-")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	require.NoError(t, json.NewEncoder(tmpFile).Encode(NewTestKeySet(key)))
+	require.NoError(t, tmpFile.Close())
+
+	keySet, err := NewFileKeySet(tmpFile.Name())
+	require.NoError(t, err)
+
+	token, err := NewTestSignedJWTWithClaims(key, Claims{Claims: NewTestRegisteredClaims(""), Scopes: []string{"s1"}})
+	require.NoError(t, err)
+
+	c, err := NewVerifier(keySet).Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s1"}, c.Scopes)
+
+	_, err = NewFileKeySet("does-not-exist.json")
+	assert.Error(t, err)
+}
+
+func TestVerifyClaims(t *testing.T) {
+	key, err := NewTestSigningKey("key1")
+	require.NoError(t, err)
+
+	server := NewTestOIDCServer(func() *jose.JSONWebKeySet { return NewTestKeySet(key) })
+	defer server.Close()
+
+	v, err := NewOIDCVerifier(context.Background(), server.URL, WithAudience("cdnvalidator", "other"), WithLeeway(time.Minute))
+	require.NoError(t, err)
+
+	valid := NewTestRegisteredClaims(server.URL, "cdnvalidator")
+
+	expired := NewTestRegisteredClaims(server.URL, "cdnvalidator")
+	expired.Expiry = josejwt.NewNumericDate(time.Now().Add(-2 * time.Minute))
+
+	skewedExpiry := NewTestRegisteredClaims(server.URL, "cdnvalidator")
+	skewedExpiry.Expiry = josejwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+
+	notYetValid := NewTestRegisteredClaims(server.URL, "cdnvalidator")
+	notYetValid.NotBefore = josejwt.NewNumericDate(time.Now().Add(10 * time.Minute))
+
+	noExpiry := NewTestRegisteredClaims(server.URL, "cdnvalidator")
+	noExpiry.Expiry = nil
+
+	tests := []struct {
+		name   string
+		claims josejwt.Claims
+		err    error
+	}{
+		{name: "valid", claims: valid},
+		{name: "second audience", claims: NewTestRegisteredClaims(server.URL, "other")},
+		{name: "expired", claims: expired, err: ErrExpired},
+		{name: "expired within clock skew", claims: skewedExpiry},
+		{name: "not valid yet", claims: notYetValid, err: ErrNotValidYet},
+		{name: "missing expiry", claims: noExpiry, err: ErrMissingExpiry},
+		{name: "wrong issuer", claims: NewTestRegisteredClaims("https://issuer.example.com", "cdnvalidator"), err: ErrInvalidIssuer},
+		{name: "wrong audience", claims: NewTestRegisteredClaims(server.URL, "another-app"), err: ErrInvalidAudience},
+		{name: "missing audience", claims: NewTestRegisteredClaims(server.URL), err: ErrInvalidAudience},
+	}
+
+	for _, test := range tests {
+		token, err := NewTestSignedJWTWithClaims(key, Claims{Claims: test.claims, Groups: []string{"g1"}})
+		require.NoError(t, err)
+
+		c, err := v.Verify(context.Background(), token)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, test.name)
+			assert.Nil(t, c, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, []string{"g1"}, c.Groups, test.name)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	server := NewTestOIDCServer(func() *jose.JSONWebKeySet { return &jose.JSONWebKeySet{} })
+	defer server.Close()
+
+	md, err := Discover(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/keys", md.JWKSURI)
+
+	// issuer must match the discovered issuer exactly
+	_, err = Discover(context.Background(), server.URL+"/")
+	assert.Error(t, err)
+
+	_, err = NewOIDCVerifier(context.Background(), "http://127.0.0.1:0")
+	assert.Error(t, err)
+}
+
+func TestValues(t *testing.T) {
+	c := &Claims{
+		Raw: map[string]interface{}{
+			"groups": []interface{}{"g1", "g2", 3},
+			"roles":  "admin",
+			"realm_access": map[string]interface{}{
+				"roles": []interface{}{"r1"},
+			},
+			"https://example.com/groups": []interface{}{"ns1"},
+			"https://example": map[string]interface{}{
+				"com/other": "shadowed",
+			},
+			"count": 3,
+		},
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "groups", want: []string{"g1", "g2"}},
+		{path: "roles", want: []string{"admin"}},
+		{path: "realm_access.roles", want: []string{"r1"}},
+		{path: "https://example.com/groups", want: []string{"ns1"}},
+		{path: "https://example.com/other", want: []string{"shadowed"}},
+		{path: "realm_access.missing", want: nil},
+		{path: "count", want: nil},
+		{path: "", want: nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, c.Values(test.path), test.path)
+	}
+}
+
+func TestVerifiedEmail(t *testing.T) {
+	verified := true
+	unverified := false
+
+	assert.Equal(t, "a@example.com", (&Claims{Email: "a@example.com"}).VerifiedEmail())
+	assert.Equal(t, "a@example.com", (&Claims{Email: "a@example.com", EmailVerified: &verified}).VerifiedEmail())
+	assert.Equal(t, "", (&Claims{Email: "a@example.com", EmailVerified: &unverified}).VerifiedEmail())
+}
@@ -0,0 +1,142 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	defaultKeySetTTL                  = time.Hour
+	defaultMinKeySetRefresh           = 10 * time.Second
+	defaultKeySetRequestTimeout       = 10 * time.Second
+	maxKeySetResponseBytes      int64 = 1 << 20
+)
+
+// KeySet provides the public keys used to verify token signatures.
+type KeySet interface {
+	// Keys returns the keys matching kid, or every key when kid is empty.
+	Keys(ctx context.Context, kid string) ([]jose.JSONWebKey, error)
+}
+
+// keyCache holds a parsed JWKS and refreshes it when it is stale or when a
+// token references a kid that is not cached yet (key rotation).
+type keyCache struct {
+	mu         sync.Mutex
+	keys       jose.JSONWebKeySet
+	fetched    time.Time
+	ttl        time.Duration
+	minRefresh time.Duration
+	fetch      func(ctx context.Context) (*jose.JSONWebKeySet, error)
+}
+
+func (k *keyCache) Keys(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.fetched.IsZero() || time.Since(k.fetched) > k.ttl {
+		if err := k.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := k.lookup(kid)
+	if len(keys) == 0 && time.Since(k.fetched) > k.minRefresh {
+		// unknown kid, the signing key may have been rotated
+		if err := k.refresh(ctx); err != nil {
+			return nil, err
+		}
+		keys = k.lookup(kid)
+	}
+
+	return keys, nil
+}
+
+func (k *keyCache) lookup(kid string) []jose.JSONWebKey {
+	if kid == "" {
+		return k.keys.Keys
+	}
+
+	return k.keys.Key(kid)
+}
+
+func (k *keyCache) refresh(ctx context.Context) error {
+	keys, err := k.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching key set: %w", err)
+	}
+
+	k.keys = *keys
+	k.fetched = time.Now()
+
+	return nil
+}
+
+// NewRemoteKeySet returns a KeySet that fetches keys from a JWKS URL.
+func NewRemoteKeySet(url string) KeySet {
+	client := &http.Client{Timeout: defaultKeySetRequestTimeout}
+
+	return &keyCache{
+		ttl:        defaultKeySetTTL,
+		minRefresh: defaultMinKeySetRefresh,
+		fetch: func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Accept", "application/json")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+			}
+
+			keys := &jose.JSONWebKeySet{}
+			if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxKeySetResponseBytes)).Decode(keys); err != nil {
+				return nil, err
+			}
+
+			return keys, nil
+		},
+	}
+}
+
+// NewFileKeySet returns a KeySet that reads keys from a local JWKS file.
+// The file is re-read when a token references an unknown kid.
+func NewFileKeySet(path string) (KeySet, error) {
+	ks := &keyCache{
+		ttl:        defaultKeySetTTL,
+		minRefresh: time.Second,
+		fetch: func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			keys := &jose.JSONWebKeySet{}
+			if err := json.Unmarshal(data, keys); err != nil {
+				return nil, err
+			}
+
+			return keys, nil
+		},
+	}
+
+	// fail fast on a missing or malformed file
+	if _, err := ks.Keys(context.Background(), ""); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// ProviderMetadata is the subset of the OpenID Provider configuration
+// needed to verify tokens.
+type ProviderMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// Discover fetches the OpenID Provider configuration published by issuer.
+func Discover(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+	url := strings.TrimSuffix(issuer, "/") + discoveryPath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{Timeout: defaultKeySetRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	md := &ProviderMetadata{}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxKeySetResponseBytes)).Decode(md); err != nil {
+		return nil, err
+	}
+
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if md.Issuer != issuer {
+		return nil, fmt.Errorf("discovered issuer %q does not match %q", md.Issuer, issuer)
+	}
+
+	if md.JWKSURI == "" {
+		return nil, fmt.Errorf("no jwks_uri published by issuer %s", issuer)
+	}
+
+	return md, nil
+}
+
+// NewOIDCVerifier discovers the keys of issuer and returns a Verifier that
+// requires tokens to be issued by it.
+func NewOIDCVerifier(ctx context.Context, issuer string, opts ...VerifierOption) (*Verifier, error) {
+	md, err := Discover(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering OIDC issuer %s: %w", issuer, err)
+	}
+
+	opts = append([]VerifierOption{WithIssuer(md.Issuer)}, opts...)
+
+	return NewVerifier(NewRemoteKeySet(md.JWKSURI), opts...), nil
+}
@@ -0,0 +1,26 @@
+package jwt
+
+import "time"
+
+type VerifierOption func(v *Verifier)
+
+// WithIssuer requires the iss claim to match issuer.
+func WithIssuer(issuer string) VerifierOption {
+	return func(v *Verifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience requires the aud claim to contain at least one of audiences.
+func WithAudience(audiences ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.audiences = audiences
+	}
+}
+
+// WithLeeway sets the allowed clock skew when validating exp and nbf.
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
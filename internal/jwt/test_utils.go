@@ -1,6 +1,13 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
 	"gopkg.in/square/go-jose.v2"
 	josejwt "gopkg.in/square/go-jose.v2/jwt"
 )
@@ -19,3 +26,80 @@ func NewTestJWTWithClaims(claims Claims) (string, error) {
 
 	return raw, nil
 }
+
+// NewTestSigningKey generates an RSA signing key identified by kid
+func NewTestSigningKey(kid string) (*jose.JSONWebKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}, nil
+}
+
+// NewTestSignedJWTWithClaims signs claims using key, extra claims are merged into the payload
+func NewTestSignedJWTWithClaims(key *jose.JSONWebKey, claims interface{}, extra ...interface{}) (string, error) {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	builder := josejwt.Signed(sig).Claims(claims)
+	for _, e := range extra {
+		builder = builder.Claims(e)
+	}
+
+	return builder.CompactSerialize()
+}
+
+// NewTestKeySet returns the public JWKS for the supplied signing keys
+func NewTestKeySet(keys ...*jose.JSONWebKey) *jose.JSONWebKeySet {
+	set := &jose.JSONWebKeySet{}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.Public())
+	}
+
+	return set
+}
+
+// NewTestJWKSServer serves the JWKS returned by keys at any path
+func NewTestJWKSServer(keys func() *jose.JSONWebKeySet) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keys())
+	}))
+}
+
+// NewTestOIDCServer serves an OpenID Provider configuration and the JWKS
+// returned by keys, the issuer is the URL of the returned server.
+func NewTestOIDCServer(keys func() *jose.JSONWebKeySet) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ProviderMetadata{
+			Issuer:  server.URL,
+			JWKSURI: server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keys())
+	})
+
+	return server
+}
+
+// NewTestRegisteredClaims returns registered claims valid for an hour
+func NewTestRegisteredClaims(issuer string, audience ...string) josejwt.Claims {
+	now := time.Now()
+
+	return josejwt.Claims{
+		Issuer:    issuer,
+		Audience:  audience,
+		IssuedAt:  josejwt.NewNumericDate(now),
+		NotBefore: josejwt.NewNumericDate(now),
+		Expiry:    josejwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoMatchingKey        = errors.New("no matching key found for token")
+	ErrInvalidSignature     = errors.New("invalid token signature")
+	ErrExpired              = errors.New("token is expired")
+	ErrNotValidYet          = errors.New("token is not valid yet")
+	ErrMissingExpiry        = errors.New("token has no expiry")
+	ErrInvalidIssuer        = errors.New("token issuer is not accepted")
+	ErrInvalidAudience      = errors.New("token audience is not accepted")
+)
+
+// supportedAlgorithms lists the asymmetric algorithms accepted by a Verifier.
+// Symmetric (HS*) algorithms are rejected since JWKS keys are public.
+var supportedAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.PS256): true,
+	string(jose.PS384): true,
+	string(jose.PS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// Verifier validates token signatures against a KeySet and checks the
+// registered iss, aud, exp and nbf claims.
+type Verifier struct {
+	keySet    KeySet
+	issuer    string
+	audiences []string
+	leeway    time.Duration
+	now       func() time.Time
+}
+
+func NewVerifier(keySet KeySet, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		keySet: keySet,
+		leeway: jwt.DefaultLeeway,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify checks the signature and registered claims of rawToken and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, rawToken string) (*Claims, error) {
+	token, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token.Headers) != 1 {
+		return nil, fmt.Errorf("expected exactly one signature, found %d", len(token.Headers))
+	}
+
+	header := token.Headers[0]
+	if !supportedAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Algorithm)
+	}
+
+	keys, err := v.keySet.Keys(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: kid %q", ErrNoMatchingKey, header.KeyID)
+	}
+
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+			continue
+		}
+
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		c := &Claims{}
+		if err := token.Claims(key, c, &c.Raw); err != nil {
+			continue
+		}
+
+		if err := v.validate(c); err != nil {
+			return nil, err
+		}
+
+		return c, nil
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	if c.Expiry == nil {
+		return ErrMissingExpiry
+	}
+
+	err := c.Claims.ValidateWithLeeway(jwt.Expected{Issuer: v.issuer, Time: v.now()}, v.leeway)
+	switch {
+	case errors.Is(err, jwt.ErrExpired):
+		return ErrExpired
+	case errors.Is(err, jwt.ErrNotValidYet):
+		return ErrNotValidYet
+	case errors.Is(err, jwt.ErrInvalidIssuer):
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, c.Issuer)
+	case err != nil:
+		return err
+	}
+
+	if len(v.audiences) == 0 {
+		return nil
+	}
+
+	for _, aud := range v.audiences {
+		if c.Audience.Contains(aud) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrInvalidAudience, []string(c.Audience))
+}
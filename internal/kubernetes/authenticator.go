@@ -0,0 +1,134 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	serviceAccountUsernamePrefix = core.ServiceAccountClaimPrefix + ":"
+	serviceAccountGroup          = core.ServiceAccountClaimPrefix + "s"
+)
+
+var (
+	ErrNotAuthenticated     = errors.New("token not authenticated")
+	ErrNotServiceAccount    = errors.New("token does not belong to a service account")
+	ErrMissingAuthenticator = errors.New("one of a TokenReview client or a verifier is required")
+)
+
+// Authenticator authenticates Kubernetes ServiceAccount tokens issued by
+// a cluster, either through the TokenReview API or offline by verifying
+// the token signature against the cluster's keys.
+type Authenticator struct {
+	issuer    string
+	audiences []string
+	client    kubernetes.Interface
+	verifier  *jwt.Verifier
+}
+
+// New returns an Authenticator for tokens with the iss claim issuer
+func New(issuer string, opts ...Option) (*Authenticator, error) {
+	a := &Authenticator{issuer: issuer}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if issuer == "" {
+		return nil, errors.New("missing required parameter issuer")
+	}
+
+	if a.client == nil && a.verifier == nil {
+		return nil, ErrMissingAuthenticator
+	}
+
+	return a, nil
+}
+
+// Issuer returns the iss claim of the tokens handled by the Authenticator
+func (a *Authenticator) Issuer() string {
+	return a.issuer
+}
+
+// AuthenticateToken returns the identity of the service account the token
+// was issued to.  The service account username and groups are used as
+// claims, e.g. system:serviceaccount:web:deployer and
+// system:serviceaccounts:web.
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*core.Identity, error) {
+	if a.client != nil {
+		return a.tokenReview(ctx, token)
+	}
+
+	return a.verify(ctx, token)
+}
+
+func (a *Authenticator) tokenReview(ctx context.Context, token string) (*core.Identity, error) {
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("TokenReview failed: %w", err)
+	}
+
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrNotAuthenticated, review.Status.Error)
+		}
+		return nil, ErrNotAuthenticated
+	}
+
+	if _, _, err := parseUsername(review.Status.User.Username); err != nil {
+		return nil, err
+	}
+
+	return identity(review.Status.User.Username, review.Status.User.Groups), nil
+}
+
+func (a *Authenticator) verify(ctx context.Context, token string) (*core.Identity, error) {
+	claims, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, _, err := parseUsername(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	// the groups the API server would assign to the service account
+	groups := []string{serviceAccountGroup, serviceAccountGroup + ":" + namespace}
+
+	return identity(claims.Subject, groups), nil
+}
+
+func identity(username string, groups []string) *core.Identity {
+	return &core.Identity{
+		Subject: username,
+		Claims:  append([]string{username}, groups...),
+	}
+}
+
+// parseUsername splits a system:serviceaccount:<namespace>:<name> username
+func parseUsername(username string) (string, string, error) {
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) {
+		return "", "", fmt.Errorf("%w: %s", ErrNotServiceAccount, username)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(username, serviceAccountUsernamePrefix), ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrNotServiceAccount, username)
+	}
+
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,120 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testIssuer = "https://kubernetes.default.svc.cluster.local"
+
+func newTestTokenReviewClient(statuses map[string]authenticationv1.TokenReviewStatus) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status = statuses[review.Spec.Token]
+		return true, review, nil
+	})
+
+	return client
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(testIssuer)
+	assert.ErrorIs(t, err, ErrMissingAuthenticator)
+
+	_, err = New("", WithTokenReview(fake.NewSimpleClientset()))
+	assert.Error(t, err)
+
+	a, err := New(testIssuer, WithTokenReview(fake.NewSimpleClientset()))
+	assert.NoError(t, err)
+	assert.Equal(t, testIssuer, a.Issuer())
+}
+
+func TestAuthenticateTokenReview(t *testing.T) {
+	client := newTestTokenReviewClient(map[string]authenticationv1.TokenReviewStatus{
+		"deployer": {
+			Authenticated: true,
+			User: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:web:deployer",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:web", "system:authenticated"},
+			},
+		},
+		"user": {
+			Authenticated: true,
+			User:          authenticationv1.UserInfo{Username: "jdoe"},
+		},
+		"expired": {
+			Error: "token has expired",
+		},
+	})
+
+	a, err := New(testIssuer, WithTokenReview(client), WithAudiences("cdnvalidator"))
+	assert.NoError(t, err)
+
+	identity, err := a.AuthenticateToken(context.Background(), "deployer")
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Identity{
+		Subject: "system:serviceaccount:web:deployer",
+		Claims:  []string{"system:serviceaccount:web:deployer", "system:serviceaccounts", "system:serviceaccounts:web", "system:authenticated"},
+	}, identity)
+
+	_, err = a.AuthenticateToken(context.Background(), "user")
+	assert.ErrorIs(t, err, ErrNotServiceAccount)
+
+	_, err = a.AuthenticateToken(context.Background(), "expired")
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+	assert.Contains(t, err.Error(), "token has expired")
+
+	_, err = a.AuthenticateToken(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+
+	// the audiences are sent with the review
+	review := client.Actions()[0].(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+	assert.Equal(t, []string{"cdnvalidator"}, review.Spec.Audiences)
+}
+
+func TestAuthenticateVerifier(t *testing.T) {
+	key, err := jwt.NewTestSigningKey("k1")
+	assert.NoError(t, err)
+
+	server := jwt.NewTestJWKSServer(func() *jose.JSONWebKeySet { return jwt.NewTestKeySet(key) })
+	defer server.Close()
+
+	verifier := jwt.NewVerifier(jwt.NewRemoteKeySet(server.URL), jwt.WithIssuer(testIssuer), jwt.WithAudience("cdnvalidator"))
+
+	a, err := New(testIssuer, WithVerifier(verifier))
+	assert.NoError(t, err)
+
+	newToken := func(subject string, aud ...string) string {
+		claims := jwt.NewTestRegisteredClaims(testIssuer, aud...)
+		claims.Subject = subject
+
+		token, err := jwt.NewTestSignedJWTWithClaims(key, jwt.Claims{Claims: claims})
+		assert.NoError(t, err)
+		return token
+	}
+
+	identity, err := a.AuthenticateToken(context.Background(), newToken("system:serviceaccount:web:deployer", "cdnvalidator"))
+	assert.NoError(t, err)
+	assert.Equal(t, &core.Identity{
+		Subject: "system:serviceaccount:web:deployer",
+		Claims:  []string{"system:serviceaccount:web:deployer", "system:serviceaccounts", "system:serviceaccounts:web"},
+	}, identity)
+
+	_, err = a.AuthenticateToken(context.Background(), newToken("system:serviceaccount:web:deployer", "other"))
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+
+	for _, subject := range []string{"jdoe", "system:serviceaccount:web", "system:serviceaccount::deployer"} {
+		_, err = a.AuthenticateToken(context.Background(), newToken(subject, "cdnvalidator"))
+		assert.ErrorIs(t, err, ErrNotServiceAccount, subject)
+	}
+}
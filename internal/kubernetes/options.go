@@ -0,0 +1,29 @@
+package kubernetes
+
+import (
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Option func(a *Authenticator)
+
+// WithTokenReview authenticates tokens through the TokenReview API of client
+func WithTokenReview(client kubernetes.Interface) Option {
+	return func(a *Authenticator) {
+		a.client = client
+	}
+}
+
+// WithVerifier authenticates tokens offline with verifier
+func WithVerifier(verifier *jwt.Verifier) Option {
+	return func(a *Authenticator) {
+		a.verifier = verifier
+	}
+}
+
+// WithAudiences requires tokens to be issued for one of audiences
+func WithAudiences(audiences ...string) Option {
+	return func(a *Authenticator) {
+		a.audiences = audiences
+	}
+}
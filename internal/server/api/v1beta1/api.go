@@ -20,6 +20,10 @@ func New(router *mux.Router, ds *v1beta1.DistributionService) *mux.Router {
 	api.HandleFunc("/distributions", getDistributions(ds)).Methods(http.MethodGet)
 	api.HandleFunc("/distributions/{name}/invalidations", createInvalidation(ds)).Methods(http.MethodPost)
 	api.HandleFunc("/distributions/{name}/invalidations/{id}", getInvalidation(ds)).Methods(http.MethodGet)
+	api.HandleFunc("/distributions/{name}/grants", listGrants(ds)).Methods(http.MethodGet)
+	api.HandleFunc("/distributions/{name}/grants", createGrant(ds)).Methods(http.MethodPost)
+	api.HandleFunc("/distributions/{name}/grants/{id}", deleteGrant(ds)).Methods(http.MethodDelete)
+	api.HandleFunc("/config/info", getConfigInfo(ds)).Methods(http.MethodGet)
 
 	return api
 }
@@ -49,7 +53,7 @@ func getDistributions(ds DistributionService) http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, map[string]interface{}{"distributions": d}, http.StatusOK)
+		writeJSON(w, v1beta1.NewDistributionsResponse(d), http.StatusOK)
 	}
 }
 
@@ -157,6 +161,132 @@ func getInvalidation(ds DistributionService) http.HandlerFunc {
 	}
 }
 
+// swagger:route GET /api/v1beta1/distributions/{name}/grants GrantsResponse list-grants
+//
+// List the temporary grants of a distribution, requires the admin role.
+//
+//     Security:
+//       jwt:
+//
+// responses:
+//   200: GrantsResponse
+//   400: InvalidationError
+//   403: InvalidationError
+//   404: InvalidationError
+//   500: ErrorResponse
+func listGrants(ds DistributionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		name := mux.Vars(r)["name"]
+
+		grants, err := ds.ListGrants(r.Context(), name)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, &v1beta1.GrantsResponse{Grants: grants}, http.StatusOK)
+	}
+}
+
+// swagger:route POST /api/v1beta1/distributions/{name}/grants TemporaryGrant create-grant
+//
+// Issue a temporary grant on a distribution, requires the admin role.
+//
+//     Security:
+//       jwt:
+//
+// responses:
+//   201: TemporaryGrant
+//   400: InvalidationError
+//   403: InvalidationError
+//   404: InvalidationError
+//   500: ErrorResponse
+func createGrant(ds DistributionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		name := mux.Vars(r)["name"]
+
+		grantReq := v1beta1.GrantRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&grantReq); err != nil {
+			writeJSON(w, &v1beta1.InvalidationMeta{Status: "invalid grant request body"}, http.StatusBadRequest)
+			return
+		}
+
+		grant, err := ds.CreateGrant(r.Context(), name, grantReq)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, grant, http.StatusCreated)
+	}
+}
+
+// swagger:route DELETE /api/v1beta1/distributions/{name}/grants/{id} TemporaryGrant delete-grant
+//
+// Revoke a temporary grant on a distribution, requires the admin role.
+//
+//     Security:
+//       jwt:
+//
+// responses:
+//   204:
+//   403: InvalidationError
+//   404: InvalidationError
+//   500: ErrorResponse
+func deleteGrant(ds DistributionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		if err := ds.DeleteGrant(r.Context(), vars["name"], vars["id"]); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// swagger:route GET /api/v1beta1/config/info ConfigInfoResponse get-config-info
+//
+// Get the hash, load time and source of the active configuration and the error of its last reload.
+//
+//     Security:
+//       jwt:
+//
+// responses:
+//   200: ConfigInfoResponse
+//   401: ErrorResponse
+//   500: ErrorResponse
+func getConfigInfo(ds DistributionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := ds.ConfigInfo(r.Context())
+		if err != nil {
+			logError(w, err, "unexpected error reading configuration info", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, info, http.StatusOK)
+	}
+}
+
+// writeServiceError writes the response for an error returned by the DistributionService
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case v1beta1.ErrorBadRequest(err):
+		writeJSON(w, err, http.StatusBadRequest)
+	case v1beta1.ErrorResourceNotFound(err):
+		writeJSON(w, err, http.StatusNotFound)
+	case v1beta1.ErrorIsUnauthorized(err):
+		writeJSON(w, err, http.StatusForbidden)
+	default:
+		logError(w, err, "unexpected error", http.StatusInternalServerError)
+	}
+}
+
 func logError(w http.ResponseWriter, err error, msg string, statusCode int) {
 	log.WithError(err).Error(msg)
 	http.Error(w, "unexpected error", statusCode)
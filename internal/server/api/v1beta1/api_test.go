@@ -11,25 +11,28 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kanopy-platform/cdnvalidator/internal/config"
 	"github.com/kanopy-platform/cdnvalidator/internal/core"
 	"github.com/kanopy-platform/cdnvalidator/internal/core/v1beta1"
 	"github.com/stretchr/testify/assert"
 )
 
 func addClaims(ctx context.Context, claims []string) context.Context {
-	return context.WithValue(ctx, core.ContextBoundaryKey, claims)
+	return core.WithIdentity(ctx, &core.Identity{Claims: claims})
 }
 
 func TestGetDistributions(t *testing.T) {
 	fake := v1beta1.NewFake()
 
 	tests := []struct {
-		claims []string
-		want   int
+		claims   []string
+		want     int
+		wantBody string
 	}{
 		{
-			claims: []string{"gr1"},
-			want:   http.StatusOK,
+			claims:   []string{"gr1"},
+			want:     http.StatusOK,
+			wantBody: `{"distributions":["f1"],"details":{"f1":{"pathPrefix":"/f1","pathPrefixes":["/f1"],"description":"Fake distribution","owners":["team-f"],"contact":"#team-f"}}}`,
 		},
 		{
 			claims: []string{},
@@ -52,6 +55,44 @@ func TestGetDistributions(t *testing.T) {
 
 		t.Logf("response: %s", rr.Body.String())
 		assert.Equal(t, test.want, rr.Code)
+		if test.wantBody != "" {
+			assert.JSONEq(t, test.wantBody, rr.Body.String())
+		}
+	}
+}
+
+func TestGetConfigInfo(t *testing.T) {
+	fake := v1beta1.NewFake()
+
+	tests := []struct {
+		claims   []string
+		want     int
+		wantBody string
+	}{
+		{
+			claims:   []string{"gr1"},
+			want:     http.StatusOK,
+			wantBody: `{"hash":"abc123","loadedAt":"1970-01-01T00:00:00Z","source":"/etc/cdnvalidator/config.yaml","lastReloadError":"error parsing configuration: unknown key polices at line 1"}`,
+		},
+		{
+			claims: []string{},
+			want:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "/config/info", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(addClaims(req.Context(), test.claims))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(getConfigInfo(fake))
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code)
+		if test.wantBody != "" {
+			assert.JSONEq(t, test.wantBody, rr.Body.String())
+		}
 	}
 }
 
@@ -91,6 +132,19 @@ func TestCreateInvalidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			claims: []string{"gr1"},
+			name:   "viewer distribution",
+			body: v1beta1.InvalidationRequest{
+				Paths: []string{"/test/*"},
+			},
+			wantCode: 403,
+			wantResponse: v1beta1.InvalidationResponse{
+				InvalidationMeta: v1beta1.InvalidationMeta{
+					Status: "User is missing permission invalidations:create on distribution: viewer distribution",
+				},
+			},
+		},
 		{
 			claims: []string{"gr1"},
 			name:   "dr1",
@@ -149,6 +203,10 @@ func TestCreateInvalidation(t *testing.T) {
 			assert.Equal(t, test.wantResponse, resp)
 		}
 
+		if test.wantCode == 403 && test.name == "viewer distribution" {
+			assert.Contains(t, rr.Body.String(), `"missingPermission":"invalidations:create"`)
+		}
+
 	}
 }
 
@@ -230,3 +288,98 @@ func TestGetInvalidation(t *testing.T) {
 
 	}
 }
+
+func TestGrants(t *testing.T) {
+	fake := v1beta1.NewFake()
+
+	newRequest := func(method, name, id string, body interface{}) *http.Request {
+		data, err := json.Marshal(body)
+		assert.NoError(t, err)
+
+		req, err := http.NewRequest(method, fmt.Sprintf("/distributions/%s/grants/%s", name, id), bytes.NewReader(data))
+		assert.NoError(t, err)
+
+		req = mux.SetURLVars(req, map[string]string{"name": name, "id": id})
+		return req.WithContext(addClaims(req.Context(), []string{"test"}))
+	}
+
+	expires := time.Unix(0, 0).UTC()
+	grantReq := v1beta1.GrantRequest{
+		Holder:  config.GrantHolder{Type: config.HolderClaim, Name: "oncall"},
+		Role:    config.RoleViewer,
+		Expires: expires,
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		req      *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "list",
+			handler:  listGrants(fake),
+			req:      newRequest(http.MethodGet, "d1", "", nil),
+			wantCode: http.StatusOK,
+			wantBody: `{"grants":[{"id":"1","holder":{"type":"","name":""},"distribution":"d1","role":"","expires":"0001-01-01T00:00:00Z","createdBy":"","created":"0001-01-01T00:00:00Z"}]}`,
+		},
+		{
+			name:     "list not found",
+			handler:  listGrants(fake),
+			req:      newRequest(http.MethodGet, "notfound", "", nil),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "create",
+			handler:  createGrant(fake),
+			req:      newRequest(http.MethodPost, "d1", "", grantReq),
+			wantCode: http.StatusCreated,
+			wantBody: `{"id":"1","holder":{"type":"claim","name":"oncall"},"distribution":"d1","role":"viewer","expires":"1970-01-01T00:00:00Z","createdBy":"","created":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			name:     "create without permission",
+			handler:  createGrant(fake),
+			req:      newRequest(http.MethodPost, "viewer distribution", "", grantReq),
+			wantCode: http.StatusForbidden,
+			wantBody: `{"status":"User is missing permission distributions:manage on distribution: viewer distribution","missingPermission":"distributions:manage"}`,
+		},
+		{
+			name:     "create invalid grant",
+			handler:  createGrant(fake),
+			req:      newRequest(http.MethodPost, "d1", "", v1beta1.GrantRequest{}),
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"status":"Bad Request: holder name must not be empty"}`,
+		},
+		{
+			name:     "create invalid body",
+			handler:  createGrant(fake),
+			req:      newRequest(http.MethodPost, "d1", "", "not a grant"),
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"status":"invalid grant request body"}`,
+		},
+		{
+			name:     "delete",
+			handler:  deleteGrant(fake),
+			req:      newRequest(http.MethodDelete, "d1", "1", nil),
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "delete not found",
+			handler:  deleteGrant(fake),
+			req:      newRequest(http.MethodDelete, "d1", "notfound", nil),
+			wantCode: http.StatusNotFound,
+			wantBody: `{"status":"Resource not found: grant notfound"}`,
+		},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		test.handler.ServeHTTP(rr, test.req)
+
+		assert.Equal(t, test.wantCode, rr.Code, test.name)
+		if test.wantBody != "" {
+			assert.JSONEq(t, test.wantBody, rr.Body.String(), test.name)
+		}
+	}
+}
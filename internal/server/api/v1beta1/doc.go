@@ -3,11 +3,16 @@
 // Scheme: https
 // Security:
 //   - jwt
+//   - apiKey
 // SecurityDefinitions:
 // jwt:
 //   type: Bearer
 //   name: Authorization
 //   in: header
+// apiKey:
+//   type: apiKey
+//   name: X-API-Key
+//   in: header
 //
 //
 // swagger:meta
@@ -3,11 +3,16 @@ package v1beta1
 import (
 	"context"
 
+	"github.com/kanopy-platform/cdnvalidator/internal/config"
 	"github.com/kanopy-platform/cdnvalidator/internal/core/v1beta1"
 )
 
 type DistributionService interface {
-	List(ctx context.Context) ([]string, error)
+	List(ctx context.Context) (map[v1beta1.VanityDistributionName]v1beta1.Distribution, error)
+	ConfigInfo(ctx context.Context) (*v1beta1.ConfigInfoResponse, error)
 	CreateInvalidation(ctx context.Context, distributionName string, paths []string) (*v1beta1.InvalidationResponse, error)
 	GetInvalidationStatus(ctx context.Context, distributionName string, invalidationID string) (*v1beta1.InvalidationResponse, error)
+	ListGrants(ctx context.Context, distributionName string) ([]config.TemporaryGrant, error)
+	CreateGrant(ctx context.Context, distributionName string, req v1beta1.GrantRequest) (*config.TemporaryGrant, error)
+	DeleteGrant(ctx context.Context, distributionName string, grantID string) error
 }
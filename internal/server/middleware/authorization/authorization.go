@@ -2,7 +2,10 @@ package authorization
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/textproto"
 	"strings"
@@ -12,10 +15,35 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ClaimsMapper extracts the claims used for entitlements from a token
+type ClaimsMapper interface {
+	ClaimsFromToken(token *jwt.Claims) []string
+}
+
+// APIKeyAuthenticator resolves the identity of a service account API key
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(apiKey string) (*core.Identity, error)
+}
+
+// TokenAuthenticator authenticates tokens of a single issuer, such as
+// Kubernetes ServiceAccount tokens, in place of the verifier
+type TokenAuthenticator interface {
+	Issuer() string
+	AuthenticateToken(ctx context.Context, token string) (*core.Identity, error)
+}
+
 type middleware struct {
-	authCookieName    string
-	authHeaderName    string
-	authHeaderEnabled bool
+	authCookieName      string
+	authHeaderName      string
+	authHeaderEnabled   bool
+	verifier            *jwt.Verifier
+	claimsMapper        ClaimsMapper
+	apiKeyHeaderName    string
+	apiKeyAuthenticator APIKeyAuthenticator
+	clientCAs           *x509.CertPool
+	forwardedCertHeader string
+	trustedProxies      []*net.IPNet
+	tokenAuthenticators map[string]TokenAuthenticator
 }
 
 func New(opts ...Option) func(http.Handler) http.Handler {
@@ -28,66 +56,190 @@ func New(opts ...Option) func(http.Handler) http.Handler {
 	return m.handler
 }
 
-func (m *middleware) addClaims(ctx context.Context, claims []string) context.Context {
-	return context.WithValue(ctx, core.ContextBoundaryKey, claims)
+func (m *middleware) addIdentity(ctx context.Context, identity *core.Identity, source core.AuthSource) context.Context {
+	return core.WithAuthSource(core.WithIdentity(ctx, identity), source)
 }
 
-func (m *middleware) getAuthorizationToken(req *http.Request) (string, error) {
-	switch {
-	case m.authHeaderEnabled:
+// getAuthorizationToken returns the token of the Authorization header, the
+// named header or, for browsers which only send the cookie, the cookie
+func (m *middleware) getAuthorizationToken(req *http.Request) (string, core.AuthSource, error) {
+	if m.authHeaderEnabled {
 		if _, ok := req.Header["Authorization"]; ok {
 			v := req.Header.Get("Authorization")
 			if strings.HasPrefix(v, "Bearer") {
 				v = strings.TrimPrefix(v, "Bearer ")
 			}
-			return v, nil
+			return v, core.AuthSourceHeader, nil
 		}
-		fallthrough
-	case m.authHeaderName != "":
+	}
+
+	if m.authHeaderName != "" {
 		protoHeaderName := textproto.CanonicalMIMEHeaderKey(m.authHeaderName)
 		if _, ok := req.Header[protoHeaderName]; ok {
-			return req.Header.Get(m.authHeaderName), nil
+			return req.Header.Get(m.authHeaderName), core.AuthSourceProxyHeader, nil
 		}
 
-		return "", fmt.Errorf("No %s header found", m.authHeaderName)
-
-	default:
-		// check cookie
-		v, err := req.Cookie(m.authCookieName)
-		if err != nil {
-			return "", err
+		if m.authCookieName == "" {
+			return "", "", fmt.Errorf("No %s header found", m.authHeaderName)
 		}
+	}
 
-		if v.Value == "" {
-			return "", fmt.Errorf("token empty")
-		}
+	// check cookie
+	v, err := req.Cookie(m.authCookieName)
+	if err != nil {
+		return "", "", err
+	}
+
+	if v.Value == "" {
+		return "", "", fmt.Errorf("token empty")
+	}
+
+	return v.Value, core.AuthSourceCookie, nil
+}
+
+func (m *middleware) getAPIKey(req *http.Request) (string, bool) {
+	if m.apiKeyHeaderName == "" || m.apiKeyAuthenticator == nil {
+		return "", false
+	}
+
+	protoHeaderName := textproto.CanonicalMIMEHeaderKey(m.apiKeyHeaderName)
+	if _, ok := req.Header[protoHeaderName]; !ok {
+		return "", false
+	}
 
-		return v.Value, nil
+	return req.Header.Get(m.apiKeyHeaderName), true
+}
+
+func (m *middleware) getTokenAuthenticator(tokenString string) TokenAuthenticator {
+	if len(m.tokenAuthenticators) == 0 {
+		return nil
+	}
+
+	claims, err := jwt.TokenClaims(tokenString)
+	if err != nil {
+		return nil
 	}
+
+	return m.tokenAuthenticators[claims.Issuer]
 }
 
 func (m *middleware) handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// service accounts authenticate with an API key instead of a token
+		if apiKey, ok := m.getAPIKey(req); ok {
+			identity, err := m.apiKeyAuthenticator.AuthenticateAPIKey(apiKey)
+			if err != nil {
+				log.WithError(err).Error("unable to authenticate api key")
+				http.Error(w, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+
+			log.WithField("identity", identity.Name()).Debug("authenticated api key")
+
+			req = req.WithContext(m.addIdentity(req.Context(), identity, core.AuthSourceAPIKey))
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// callers presenting a verified client certificate are identified by it
+		cert, err := m.getClientCertificate(req)
+		if err != nil {
+			log.WithError(err).Error("unable to verify client certificate")
+			http.Error(w, "invalid client certificate", http.StatusUnauthorized)
+			return
+		}
+
+		if cert != nil {
+			identity := certificateIdentity(cert)
+			log.WithField("identity", identity.Name()).Debug("authenticated client certificate")
+
+			req = req.WithContext(m.addIdentity(req.Context(), identity, core.AuthSourceCertificate))
+			next.ServeHTTP(w, req)
+			return
+		}
+
 		// process entitlement logic
 
-		tokenString, err := m.getAuthorizationToken(req)
+		tokenString, source, err := m.getAuthorizationToken(req)
 		if err != nil {
 			log.WithError(err).Error("no authorization token found")
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		tokenClaims, err := jwt.TokenClaims(tokenString)
-		if err != nil {
-			log.WithError(err).Error("unable to parse token claims")
-			http.Error(w, "invalid token", http.StatusForbidden)
+		// tokens of issuers with a dedicated authenticator, routed by the unverified iss claim
+		if authenticator := m.getTokenAuthenticator(tokenString); authenticator != nil {
+			identity, err := authenticator.AuthenticateToken(req.Context(), tokenString)
+			if err != nil {
+				log.WithError(err).WithField("issuer", authenticator.Issuer()).Error("unable to authenticate token")
+				http.Error(w, verificationErrorMessage(err), http.StatusUnauthorized)
+				return
+			}
+
+			req = req.WithContext(m.addIdentity(req.Context(), identity, source))
+			next.ServeHTTP(w, req)
 			return
 		}
 
-		claims := append(tokenClaims.Groups, tokenClaims.Scopes...)
+		var tokenClaims *jwt.Claims
+		if m.verifier != nil {
+			tokenClaims, err = m.verifier.Verify(req.Context(), tokenString)
+			if err != nil {
+				log.WithError(err).Error("unable to verify token")
+				http.Error(w, verificationErrorMessage(err), http.StatusUnauthorized)
+				return
+			}
+		} else {
+			// trust upstream, the token was validated by a forward auth proxy
+			tokenClaims, err = jwt.TokenClaims(tokenString)
+			if err != nil {
+				log.WithError(err).Error("unable to parse token claims")
+				http.Error(w, "invalid token", http.StatusForbidden)
+				return
+			}
+		}
+
+		var claims []string
+		if m.claimsMapper != nil {
+			claims = m.claimsMapper.ClaimsFromToken(tokenClaims)
+		} else {
+			claims = append(tokenClaims.Groups, tokenClaims.Scopes...)
+		}
+		// token groups must not pass for claims of other authentication methods
+		claims = core.WithoutReservedClaims(claims)
+
+		identity := &core.Identity{
+			Subject:           tokenClaims.Subject,
+			Email:             tokenClaims.VerifiedEmail(),
+			PreferredUsername: tokenClaims.PreferredUsername,
+			Claims:            claims,
+		}
 
 		// add information to context
-		req = req.WithContext(m.addClaims(req.Context(), claims))
+		req = req.WithContext(m.addIdentity(req.Context(), identity, source))
 		next.ServeHTTP(w, req)
 	})
 }
+
+// verificationErrorMessage returns a client facing reason for a token
+// verification failure without leaking unexpected internal errors.
+func verificationErrorMessage(err error) string {
+	reasons := []error{
+		jwt.ErrExpired,
+		jwt.ErrNotValidYet,
+		jwt.ErrMissingExpiry,
+		jwt.ErrInvalidIssuer,
+		jwt.ErrInvalidAudience,
+		jwt.ErrInvalidSignature,
+		jwt.ErrNoMatchingKey,
+		jwt.ErrUnsupportedAlgorithm,
+	}
+
+	for _, reason := range reasons {
+		if errors.Is(err, reason) {
+			return fmt.Sprintf("invalid token: %s", reason)
+		}
+	}
+
+	return "invalid token"
+}
@@ -1,24 +1,34 @@
 package authorization
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kanopy-platform/cdnvalidator/internal/core"
 	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
 )
 
 type Mock struct {
-	Claims []string
+	Claims   []string
+	Identity *core.Identity
+	Source   core.AuthSource
 }
 
 // e.g. http.HandleFunc("/health-check", HealthCheckHandler)
 func (m *Mock) MockContextHandler(w http.ResponseWriter, r *http.Request) {
 	// inspect context
-	m.Claims = r.Context().Value(core.ContextBoundaryKey).([]string)
+	m.Identity = core.GetIdentity(r.Context())
+	m.Claims = m.Identity.Claims
+	m.Source = core.GetAuthSource(r.Context())
 
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
@@ -30,8 +40,11 @@ func TestAuthorizationContext(t *testing.T) {
 	// pass 'nil' as the third parameter.
 
 	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{
-		Groups: []string{"g1"},
-		Scopes: []string{"g2"},
+		Claims:            josejwt.Claims{Subject: "00u123"},
+		Groups:            []string{"g1"},
+		Scopes:            []string{"g2"},
+		Email:             "jdoe@example.com",
+		PreferredUsername: "jdoe",
 	})
 
 	assert.NoError(t, err)
@@ -50,7 +63,12 @@ func TestAuthorizationContext(t *testing.T) {
 	// wrap the test handler in the authz middleware
 	middleware(handler).ServeHTTP(rr, req)
 
-	assert.Equal(t, []string{"g1", "g2"}, m.Claims)
+	assert.Equal(t, &core.Identity{
+		Subject:           "00u123",
+		Email:             "jdoe@example.com",
+		PreferredUsername: "jdoe",
+		Claims:            []string{"g1", "g2"},
+	}, m.Identity)
 }
 
 func TestAuthorizationResponses(t *testing.T) {
@@ -153,7 +171,7 @@ func TestAuthorizationResponses(t *testing.T) {
 			token:      rawToken,
 			middleware: New(WithCookieName("testme"), WithHeaderName("invalid")),
 			name:       "empty Named Header, invalid cookie",
-			want:       401,
+			want:       200,
 		},
 		{
 			token:      "invalid",
@@ -188,3 +206,326 @@ func TestAuthorizationResponses(t *testing.T) {
 		assert.Equal(t, test.want, rr.Result().StatusCode, test.name)
 	}
 }
+
+func TestAuthorizationVerifier(t *testing.T) {
+	key, err := jwt.NewTestSigningKey("key1")
+	assert.NoError(t, err)
+	other, err := jwt.NewTestSigningKey("key1")
+	assert.NoError(t, err)
+
+	server := jwt.NewTestOIDCServer(func() *jose.JSONWebKeySet { return jwt.NewTestKeySet(key) })
+	defer server.Close()
+
+	claims := jwt.Claims{Claims: jwt.NewTestRegisteredClaims(server.URL, "cdnvalidator"), Groups: []string{"g1"}}
+
+	signed, err := jwt.NewTestSignedJWTWithClaims(key, claims)
+	assert.NoError(t, err)
+	wrongSignature, err := jwt.NewTestSignedJWTWithClaims(other, claims)
+	assert.NoError(t, err)
+	unverified, err := jwt.NewTestJWTWithClaims(claims)
+	assert.NoError(t, err)
+
+	expiredClaims := jwt.Claims{Claims: jwt.NewTestRegisteredClaims(server.URL, "cdnvalidator")}
+	expiredClaims.Expiry = josejwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expired, err := jwt.NewTestSignedJWTWithClaims(key, expiredClaims)
+	assert.NoError(t, err)
+
+	wrongAudience, err := jwt.NewTestSignedJWTWithClaims(key, jwt.Claims{Claims: jwt.NewTestRegisteredClaims(server.URL, "other")})
+	assert.NoError(t, err)
+
+	verifier, err := jwt.NewOIDCVerifier(context.Background(), server.URL, jwt.WithAudience("cdnvalidator"))
+	assert.NoError(t, err)
+
+	middleware := New(WithAuthorizationHeader(), WithVerifier(verifier))
+
+	tests := []struct {
+		name     string
+		token    string
+		want     int
+		wantBody string
+	}{
+		{name: "signed token", token: signed, want: http.StatusOK},
+		{name: "wrong signature", token: wrongSignature, want: http.StatusUnauthorized, wantBody: "invalid token: invalid token signature"},
+		{name: "unverifiable token", token: unverified, want: http.StatusUnauthorized, wantBody: "invalid token: unsupported signing algorithm"},
+		{name: "invalid token", token: "invalid", want: http.StatusUnauthorized, wantBody: "invalid token"},
+		{name: "expired token", token: expired, want: http.StatusUnauthorized, wantBody: "invalid token: token is expired"},
+		{name: "wrong audience", token: wrongAudience, want: http.StatusUnauthorized, wantBody: "invalid token: token audience is not accepted"},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req, err := http.NewRequest("GET", "/some-auth-path", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.token))
+
+		rr := httptest.NewRecorder()
+		middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+		if test.wantBody != "" {
+			assert.Equal(t, test.wantBody, strings.TrimSpace(rr.Body.String()), test.name)
+		}
+		if test.want == http.StatusOK {
+			assert.Equal(t, []string{"g1"}, m.Claims, test.name)
+		}
+	}
+}
+
+type prefixMapper struct{}
+
+func (p *prefixMapper) ClaimsFromToken(token *jwt.Claims) []string {
+	claims := []string{}
+	for _, g := range token.Values("groups") {
+		claims = append(claims, "group:"+g)
+	}
+	return claims
+}
+
+func TestAuthorizationClaimsMapper(t *testing.T) {
+	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{
+		Groups: []string{"g1"},
+		Scopes: []string{"g2"},
+	})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("GET", "/some-auth-path", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rawToken))
+
+	m := &Mock{}
+	rr := httptest.NewRecorder()
+	New(WithAuthorizationHeader(), WithClaimsMapper(&prefixMapper{}))(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{"group:g1"}, m.Claims)
+}
+
+func TestAuthorizationReservedClaims(t *testing.T) {
+	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{
+		Groups: []string{"g1", "cn:deployer", "ou:platform", "spiffe://cluster.local/ns/web/sa/deployer"},
+		Scopes: []string{"system:serviceaccount:web:deployer", "system:serviceaccounts:web"},
+	})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("GET", "/some-auth-path", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rawToken))
+
+	m := &Mock{}
+	rr := httptest.NewRecorder()
+	New(WithAuthorizationHeader())(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{"g1"}, m.Claims)
+}
+
+type fakeAPIKeys struct{}
+
+func (f *fakeAPIKeys) AuthenticateAPIKey(apiKey string) (*core.Identity, error) {
+	if apiKey == "ci:secret" {
+		return &core.Identity{ServiceAccount: "ci"}, nil
+	}
+	return nil, errors.New("invalid api key")
+}
+
+func TestAuthorizationAPIKey(t *testing.T) {
+	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{Groups: []string{"g1"}})
+	assert.NoError(t, err)
+
+	middleware := New(WithAuthorizationHeader(), WithAPIKeyHeader("X-API-Key", &fakeAPIKeys{}))
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		token        string
+		want         int
+		wantIdentity *core.Identity
+	}{
+		{name: "valid api key", apiKey: "ci:secret", want: http.StatusOK, wantIdentity: &core.Identity{ServiceAccount: "ci"}},
+		{name: "invalid api key", apiKey: "ci:wrong", want: http.StatusUnauthorized},
+		{name: "api key takes precedence over token", apiKey: "ci:wrong", token: rawToken, want: http.StatusUnauthorized},
+		{name: "token without api key", token: rawToken, want: http.StatusOK, wantIdentity: &core.Identity{Claims: []string{"g1"}}},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req, err := http.NewRequest("GET", "/some-auth-path", nil)
+		assert.NoError(t, err)
+		if test.apiKey != "" {
+			req.Header.Set("x-api-key", test.apiKey)
+		}
+		if test.token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.token))
+		}
+
+		rr := httptest.NewRecorder()
+		middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+		assert.Equal(t, test.wantIdentity, m.Identity, test.name)
+	}
+}
+
+type fakeTokenAuthenticator struct{}
+
+func (f *fakeTokenAuthenticator) Issuer() string {
+	return "https://kubernetes.default.svc"
+}
+
+func (f *fakeTokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*core.Identity, error) {
+	claims, err := jwt.TokenClaims(token)
+	if err != nil || claims.Subject != "system:serviceaccount:web:deployer" {
+		return nil, errors.New("token not authenticated")
+	}
+	return &core.Identity{Subject: claims.Subject, Claims: []string{claims.Subject}}, nil
+}
+
+func TestAuthorizationTokenAuthenticator(t *testing.T) {
+	newToken := func(issuer, subject string) string {
+		token, err := jwt.NewTestJWTWithClaims(jwt.Claims{
+			Claims: josejwt.Claims{Issuer: issuer, Subject: subject},
+			Groups: []string{"g1"},
+		})
+		assert.NoError(t, err)
+		return token
+	}
+
+	middleware := New(WithAuthorizationHeader(), WithTokenAuthenticator(&fakeTokenAuthenticator{}))
+
+	tests := []struct {
+		name         string
+		token        string
+		want         int
+		wantIdentity *core.Identity
+	}{
+		{
+			name:         "routed by issuer",
+			token:        newToken("https://kubernetes.default.svc", "system:serviceaccount:web:deployer"),
+			want:         http.StatusOK,
+			wantIdentity: &core.Identity{Subject: "system:serviceaccount:web:deployer", Claims: []string{"system:serviceaccount:web:deployer"}},
+		},
+		{
+			name:  "rejected by authenticator",
+			token: newToken("https://kubernetes.default.svc", "system:serviceaccount:web:other"),
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:         "other issuers are handled as before",
+			token:        newToken("https://idp.example.com", "00u123"),
+			want:         http.StatusOK,
+			wantIdentity: &core.Identity{Subject: "00u123", Claims: []string{"g1"}},
+		},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req, err := http.NewRequest("GET", "/some-auth-path", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.token))
+
+		rr := httptest.NewRecorder()
+		middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+		assert.Equal(t, test.wantIdentity, m.Identity, test.name)
+	}
+}
+
+func TestAuthorizationCookieFallback(t *testing.T) {
+	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{Groups: []string{"g1"}})
+	assert.NoError(t, err)
+
+	// browsers send only the cookie, the token headers are optional
+	middleware := New(WithAuthorizationHeader(), WithHeaderName("X-Token"), WithCookieName("auth_token"))
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   int
+	}{
+		{name: "cookie", cookie: "auth_token", want: http.StatusOK},
+		{name: "other cookie", cookie: "other", want: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req, err := http.NewRequest("GET", "/some-auth-path", nil)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: test.cookie, Value: rawToken})
+
+		rr := httptest.NewRecorder()
+		middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+	}
+
+	// without a cookie name the named header is required
+	req, err := http.NewRequest("GET", "/some-auth-path", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	New(WithHeaderName("X-Token"))(http.HandlerFunc((&Mock{}).MockContextHandler)).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthorizationSource(t *testing.T) {
+	rawToken, err := jwt.NewTestJWTWithClaims(jwt.Claims{Groups: []string{"g1"}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		setup      func(req *http.Request)
+		want       core.AuthSource
+	}{
+		{
+			name:       "cookie",
+			middleware: New(WithCookieName("auth_token")),
+			setup:      func(req *http.Request) { req.AddCookie(&http.Cookie{Name: "auth_token", Value: rawToken}) },
+			want:       core.AuthSourceCookie,
+		},
+		{
+			name:       "authorization header",
+			middleware: New(WithCookieName("auth_token"), WithAuthorizationHeader()),
+			setup:      func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+rawToken) },
+			want:       core.AuthSourceHeader,
+		},
+		{
+			name:       "cookie without headers",
+			middleware: New(WithCookieName("auth_token"), WithAuthorizationHeader(), WithHeaderName("X-Token")),
+			setup:      func(req *http.Request) { req.AddCookie(&http.Cookie{Name: "auth_token", Value: rawToken}) },
+			want:       core.AuthSourceCookie,
+		},
+		{
+			name:       "named header",
+			middleware: New(WithHeaderName("X-Token")),
+			setup:      func(req *http.Request) { req.Header.Set("X-Token", rawToken) },
+			want:       core.AuthSourceProxyHeader,
+		},
+		{
+			name:       "api key",
+			middleware: New(WithCookieName("auth_token"), WithAPIKeyHeader("X-API-Key", &fakeAPIKeys{})),
+			setup: func(req *http.Request) {
+				req.Header.Set("X-API-Key", "ci:secret")
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: rawToken})
+			},
+			want: core.AuthSourceAPIKey,
+		},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req := httptest.NewRequest("GET", "/some-auth-path", nil)
+		test.setup(req)
+
+		rr := httptest.NewRecorder()
+		test.middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code, test.name)
+		assert.Equal(t, test.want, m.Source, test.name)
+	}
+}
@@ -0,0 +1,116 @@
+package authorization
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+)
+
+const (
+	certificateCNPrefix = core.CertificateCNClaimPrefix
+	certificateOUPrefix = core.CertificateOUClaimPrefix
+	spiffeScheme        = "spiffe"
+)
+
+// getClientCertificate returns the verified client certificate of the
+// request, either terminated by the server or forwarded by a trusted proxy.
+func (m *middleware) getClientCertificate(req *http.Request) (*x509.Certificate, error) {
+	if m.clientCAs == nil {
+		return nil, nil
+	}
+
+	// the TLS handshake verified the certificate against the client CAs
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		return req.TLS.VerifiedChains[0][0], nil
+	}
+
+	if m.forwardedCertHeader == "" || req.Header.Get(m.forwardedCertHeader) == "" {
+		return nil, nil
+	}
+
+	if !m.fromTrustedProxy(req) {
+		return nil, errors.New("forwarded client certificate from untrusted address")
+	}
+
+	cert, err := parseForwardedCertificate(req.Header.Get(m.forwardedCertHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     m.clientCAs,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+func (m *middleware) fromTrustedProxy(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range m.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseForwardedCertificate decodes a URL encoded PEM certificate as
+// forwarded by e.g. nginx $ssl_client_escaped_cert
+func parseForwardedCertificate(value string) (*x509.Certificate, error) {
+	decoded, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(decoded))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("forwarded client certificate is not a PEM certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certificateIdentity maps the subject CN, OUs and SPIFFE URI SANs of a
+// client certificate to claims
+func certificateIdentity(cert *x509.Certificate) *core.Identity {
+	identity := &core.Identity{
+		Certificate: cert.Subject.CommonName,
+		Claims:      []string{},
+	}
+
+	if cert.Subject.CommonName != "" {
+		identity.Claims = append(identity.Claims, certificateCNPrefix+cert.Subject.CommonName)
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		identity.Claims = append(identity.Claims, certificateOUPrefix+ou)
+	}
+
+	for _, uri := range cert.URIs {
+		if uri.Scheme == spiffeScheme {
+			identity.Claims = append(identity.Claims, uri.String())
+			// prefer the SPIFFE ID to name the workload
+			identity.Certificate = uri.String()
+		}
+	}
+
+	return identity
+}
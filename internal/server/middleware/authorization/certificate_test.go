@@ -0,0 +1,183 @@
+package authorization
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, uris ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		assert.NoError(t, err)
+		template.URIs = append(template.URIs, parsed)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return cert
+}
+
+func escapedPEM(cert *x509.Certificate) string {
+	return url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	ca := newTestCA(t)
+
+	cert := ca.issue(t, pkix.Name{CommonName: "deployer", OrganizationalUnit: []string{"platform", "web"}})
+	identity := certificateIdentity(cert)
+	assert.Equal(t, "deployer", identity.Certificate)
+	assert.ElementsMatch(t, []string{"cn:deployer", "ou:platform", "ou:web"}, identity.Claims)
+
+	cert = ca.issue(t, pkix.Name{CommonName: "deployer"}, "spiffe://cluster.local/ns/web/sa/deployer", "https://example.com")
+	assert.Equal(t, &core.Identity{
+		Certificate: "spiffe://cluster.local/ns/web/sa/deployer",
+		Claims:      []string{"cn:deployer", "spiffe://cluster.local/ns/web/sa/deployer"},
+	}, certificateIdentity(cert))
+}
+
+func TestAuthorizationClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, pkix.Name{CommonName: "deployer"})
+	untrusted := newTestCA(t).issue(t, pkix.Name{CommonName: "deployer"})
+
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	middleware := New(
+		WithAuthorizationHeader(),
+		WithClientCertificates(ca.pool()),
+		WithForwardedClientCertificates("X-Client-Cert", []*net.IPNet{proxies}),
+	)
+
+	wantIdentity := &core.Identity{Certificate: "deployer", Claims: []string{"cn:deployer"}}
+
+	tests := []struct {
+		name         string
+		tls          *tls.ConnectionState
+		remoteAddr   string
+		forwarded    string
+		want         int
+		wantIdentity *core.Identity
+	}{
+		{
+			name:         "verified during handshake",
+			tls:          &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert, ca.cert}}},
+			want:         http.StatusOK,
+			wantIdentity: wantIdentity,
+		},
+		{
+			name:         "forwarded by trusted proxy",
+			remoteAddr:   "10.1.2.3:4567",
+			forwarded:    escapedPEM(cert),
+			want:         http.StatusOK,
+			wantIdentity: wantIdentity,
+		},
+		{
+			name:       "forwarded by untrusted address",
+			remoteAddr: "192.168.1.1:4567",
+			forwarded:  escapedPEM(cert),
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "forwarded certificate from unknown CA",
+			remoteAddr: "10.1.2.3:4567",
+			forwarded:  escapedPEM(untrusted),
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "forwarded garbage",
+			remoteAddr: "10.1.2.3:4567",
+			forwarded:  "not-a-cert",
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "no certificate falls back to token",
+			remoteAddr: "10.1.2.3:4567",
+			want:       http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		m := &Mock{}
+
+		req := httptest.NewRequest("GET", "/some-auth-path", nil)
+		req.TLS = test.tls
+		if test.remoteAddr != "" {
+			req.RemoteAddr = test.remoteAddr
+		}
+		if test.forwarded != "" {
+			req.Header.Set("X-Client-Cert", test.forwarded)
+		}
+
+		rr := httptest.NewRecorder()
+		middleware(http.HandlerFunc(m.MockContextHandler)).ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+		assert.Equal(t, test.wantIdentity, m.Identity, test.name)
+	}
+}
@@ -1,5 +1,12 @@
 package authorization
 
+import (
+	"crypto/x509"
+	"net"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+)
+
 type Option func(m *middleware)
 
 func WithCookieName(name string) Option {
@@ -19,3 +26,57 @@ func WithAuthorizationHeader() Option {
 		m.authHeaderEnabled = true
 	}
 }
+
+// WithVerifier enables signature verification of tokens. When unset tokens
+// are trusted as validated by an upstream forward auth proxy.
+func WithVerifier(v *jwt.Verifier) Option {
+	return func(m *middleware) {
+		m.verifier = v
+	}
+}
+
+// WithClaimsMapper configures how entitlement claims are extracted from a
+// token. When unset the groups and scp claims are used.
+func WithClaimsMapper(mapper ClaimsMapper) Option {
+	return func(m *middleware) {
+		m.claimsMapper = mapper
+	}
+}
+
+// WithAPIKeyHeader enables service account authentication with an API key
+// sent in the named header
+func WithAPIKeyHeader(name string, authenticator APIKeyAuthenticator) Option {
+	return func(m *middleware) {
+		m.apiKeyHeaderName = name
+		m.apiKeyAuthenticator = authenticator
+	}
+}
+
+// WithClientCertificates authenticates callers presenting a client
+// certificate issued by one of clientCAs
+func WithClientCertificates(clientCAs *x509.CertPool) Option {
+	return func(m *middleware) {
+		m.clientCAs = clientCAs
+	}
+}
+
+// WithForwardedClientCertificates accepts URL encoded PEM client
+// certificates forwarded in header by proxies within trustedProxies that
+// terminate TLS.  Requires WithClientCertificates.
+func WithForwardedClientCertificates(header string, trustedProxies []*net.IPNet) Option {
+	return func(m *middleware) {
+		m.forwardedCertHeader = header
+		m.trustedProxies = trustedProxies
+	}
+}
+
+// WithTokenAuthenticator authenticates tokens issued by the issuer of
+// authenticator with it instead of the verifier
+func WithTokenAuthenticator(authenticator TokenAuthenticator) Option {
+	return func(m *middleware) {
+		if m.tokenAuthenticators == nil {
+			m.tokenAuthenticators = map[string]TokenAuthenticator{}
+		}
+		m.tokenAuthenticators[authenticator.Issuer()] = authenticator
+	}
+}
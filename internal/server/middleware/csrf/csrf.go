@@ -0,0 +1,154 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCookieName = "csrf_token"
+	defaultHeaderName = "X-CSRF-Token"
+	tokenBytes        = 32
+)
+
+// Guard protects cookie authenticated state changing requests from cross
+// site request forgery with a double submit token.  The token is issued in
+// a cookie and must be echoed in a header, which a cross site page cannot
+// read or set.
+type Guard struct {
+	cookieName     string
+	headerName     string
+	allowedOrigins map[string]struct{}
+}
+
+func New(opts ...Option) *Guard {
+	g := &Guard{
+		cookieName:     defaultCookieName,
+		headerName:     defaultHeaderName,
+		allowedOrigins: map[string]struct{}{},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// HeaderName returns the header the token must be submitted in
+func (g *Guard) HeaderName() string {
+	return g.headerName
+}
+
+// Token returns the CSRF token of the browser, issuing a new token cookie
+// when none is present
+func (g *Guard) Token(w http.ResponseWriter, req *http.Request) (string, error) {
+	if cookie, err := req.Cookie(g.cookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     g.cookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isSecure(req),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token, nil
+}
+
+// Handler rejects state changing requests authenticated by the cookie, or
+// by the header a proxy fills from it, without a valid token or from an
+// origin that is not allowed.  Requests authenticated by the Authorization
+// header, API key or client certificate are exempt as browsers do not attach
+// those credentials to cross site requests.
+func (g *Guard) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if isSafeMethod(req.Method) || !core.GetAuthSource(req.Context()).FromBrowser() {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if origin := requestOrigin(req); origin != "" && !g.originAllowed(req, origin) {
+			log.WithField("origin", origin).Error("csrf origin not allowed")
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		if !g.tokenValid(req) {
+			log.Error("csrf token missing or invalid")
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (g *Guard) tokenValid(req *http.Request) bool {
+	cookie, err := req.Cookie(g.cookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	header := req.Header.Get(g.headerName)
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
+
+// originAllowed accepts the origin of the request itself and the allowlist
+func (g *Guard) originAllowed(req *http.Request, origin string) bool {
+	if _, ok := g.allowedOrigins[origin]; ok {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+// requestOrigin returns the Origin header, falling back to the origin of
+// the Referer, or empty when the browser sent neither
+func requestOrigin(req *http.Request) string {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+
+	referer, err := url.Parse(req.Header.Get("Referer"))
+	if err != nil || referer.Host == "" {
+		return ""
+	}
+
+	return referer.Scheme + "://" + referer.Host
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+func isSecure(req *http.Request) bool {
+	return req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,103 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToken(t *testing.T) {
+	g := New()
+
+	// a new token is issued in a cookie
+	rr := httptest.NewRecorder()
+	token, err := g.Token(rr, httptest.NewRequest("GET", "/", nil))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	cookies := rr.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "csrf_token", cookies[0].Name)
+		assert.Equal(t, token, cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.False(t, cookies[0].Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	}
+
+	// an existing token is reused
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	reused, err := g.Token(rr, req)
+	assert.NoError(t, err)
+	assert.Equal(t, token, reused)
+	assert.Empty(t, rr.Result().Cookies())
+
+	// cookies are secure behind a TLS terminating proxy
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	_, err = g.Token(rr, req)
+	assert.NoError(t, err)
+	assert.True(t, rr.Result().Cookies()[0].Secure)
+}
+
+func TestHandler(t *testing.T) {
+	g := New(WithAllowedOrigins("https://portal.example.com/"))
+	handler := g.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name    string
+		method  string
+		source  core.AuthSource
+		cookie  string
+		header  string
+		origin  string
+		referer string
+		want    int
+	}{
+		{name: "safe method", method: "GET", source: core.AuthSourceCookie, want: http.StatusOK},
+		{name: "header auth is exempt", method: "POST", source: core.AuthSourceHeader, want: http.StatusOK},
+		{name: "proxy header auth", method: "POST", source: core.AuthSourceProxyHeader, want: http.StatusForbidden},
+		{name: "proxy header auth with token", method: "POST", source: core.AuthSourceProxyHeader, cookie: "t1", header: "t1", want: http.StatusOK},
+		{name: "proxy header auth cross origin", method: "POST", source: core.AuthSourceProxyHeader, cookie: "t1", header: "t1", origin: "https://evil.example.com", want: http.StatusForbidden},
+		{name: "api key auth is exempt", method: "POST", source: core.AuthSourceAPIKey, origin: "https://evil.example.com", want: http.StatusOK},
+		{name: "valid token", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", want: http.StatusOK},
+		{name: "missing token", method: "POST", source: core.AuthSourceCookie, want: http.StatusForbidden},
+		{name: "missing header", method: "DELETE", source: core.AuthSourceCookie, cookie: "t1", want: http.StatusForbidden},
+		{name: "mismatched token", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t2", want: http.StatusForbidden},
+		{name: "same origin", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", origin: "https://cdn.example.com", want: http.StatusOK},
+		{name: "allowed origin", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", origin: "https://portal.example.com", want: http.StatusOK},
+		{name: "cross origin", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", origin: "https://evil.example.com", want: http.StatusForbidden},
+		{name: "null origin", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", origin: "null", want: http.StatusForbidden},
+		{name: "cross origin referer", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", referer: "https://evil.example.com/page", want: http.StatusForbidden},
+		{name: "same origin referer", method: "POST", source: core.AuthSourceCookie, cookie: "t1", header: "t1", referer: "https://cdn.example.com/", want: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "https://cdn.example.com/api/v1beta1/distributions/d1/invalidations", nil)
+		req = req.WithContext(core.WithAuthSource(req.Context(), test.source))
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: test.cookie})
+		}
+		if test.header != "" {
+			req.Header.Set("X-CSRF-Token", test.header)
+		}
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		if test.referer != "" {
+			req.Header.Set("Referer", test.referer)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, test.want, rr.Code, test.name)
+	}
+}
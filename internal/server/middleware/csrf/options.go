@@ -0,0 +1,27 @@
+package csrf
+
+import "strings"
+
+type Option func(g *Guard)
+
+func WithCookieName(name string) Option {
+	return func(g *Guard) {
+		g.cookieName = name
+	}
+}
+
+func WithHeaderName(name string) Option {
+	return func(g *Guard) {
+		g.headerName = name
+	}
+}
+
+// WithAllowedOrigins allows state changing requests from origins other
+// than the server itself, e.g. https://portal.example.com
+func WithAllowedOrigins(origins ...string) Option {
+	return func(g *Guard) {
+		for _, origin := range origins {
+			g.allowedOrigins[strings.TrimSuffix(origin, "/")] = struct{}{}
+		}
+	}
+}
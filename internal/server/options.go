@@ -1,5 +1,14 @@
 package server
 
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
+	"github.com/kanopy-platform/cdnvalidator/internal/server/middleware/authorization"
+)
+
 type Option func(*Server) error
 
 func WithAuthCookieName(name string) Option {
@@ -15,3 +24,57 @@ func WithAuthHeaderName(name string) Option {
 		return nil
 	}
 }
+
+func WithJWTVerifier(v *jwt.Verifier) Option {
+	return func(s *Server) error {
+		s.verifier = v
+		return nil
+	}
+}
+
+func WithAPIKeyHeaderName(name string) Option {
+	return func(s *Server) error {
+		s.apiKeyHeaderName = name
+		return nil
+	}
+}
+
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(s *Server) error {
+		s.clientCAs = pool
+		return nil
+	}
+}
+
+func WithForwardedClientCertificates(header string, trustedProxies []string) Option {
+	return func(s *Server) error {
+		s.clientCertHeader = header
+		s.trustedProxies = nil
+
+		for _, cidr := range trustedProxies {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			s.trustedProxies = append(s.trustedProxies, network)
+		}
+
+		return nil
+	}
+}
+
+func WithTokenAuthenticator(auth authorization.TokenAuthenticator) Option {
+	return func(s *Server) error {
+		s.tokenAuths = append(s.tokenAuths, auth)
+		return nil
+	}
+}
+
+// WithCSRFAllowedOrigins allows cookie authenticated requests from origins
+// other than the server itself
+func WithCSRFAllowedOrigins(origins []string) Option {
+	return func(s *Server) error {
+		s.csrfOrigins = origins
+		return nil
+	}
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
+	"crypto/x509"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -15,8 +17,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/kanopy-platform/cdnvalidator/internal/config"
 	v1beta1_ds "github.com/kanopy-platform/cdnvalidator/internal/core/v1beta1"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
 	"github.com/kanopy-platform/cdnvalidator/internal/server/api/v1beta1"
 	"github.com/kanopy-platform/cdnvalidator/internal/server/middleware/authorization"
+	"github.com/kanopy-platform/cdnvalidator/internal/server/middleware/csrf"
 	"github.com/kanopy-platform/cdnvalidator/pkg/aws/cloudfront"
 	"github.com/kanopy-platform/cdnvalidator/pkg/http/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -27,10 +31,18 @@ import (
 var embeddedFS embed.FS
 
 type Server struct {
-	router         *mux.Router
-	template       *template.Template
-	authCookieName string
-	authHeaderName string
+	router           *mux.Router
+	template         *template.Template
+	authCookieName   string
+	authHeaderName   string
+	verifier         *jwt.Verifier
+	apiKeyHeaderName string
+	clientCAs        *x509.CertPool
+	clientCertHeader string
+	trustedProxies   []*net.IPNet
+	tokenAuths       []authorization.TokenAuthenticator
+	csrfOrigins      []string
+	csrf             *csrf.Guard
 }
 
 func New(config *config.Config, cloudfront *cloudfront.Client, opts ...Option) (http.Handler, error) {
@@ -52,6 +64,8 @@ func New(config *config.Config, cloudfront *cloudfront.Client, opts ...Option) (
 		}
 	}
 
+	s.csrf = csrf.New(csrf.WithAllowedOrigins(s.csrfOrigins...))
+
 	s.router.Use(prometheus.New())
 	s.router.Use(logRequestHandler)
 	s.router.HandleFunc("/", s.handleRoot())
@@ -60,21 +74,57 @@ func New(config *config.Config, cloudfront *cloudfront.Client, opts ...Option) (
 	s.router.PathPrefix("/swagger/").Handler(http.StripPrefix("/swagger", http.FileServer(http.Dir("swagger"))))
 	s.router.PathPrefix("/ui/").Handler(http.FileServer(http.FS(embeddedFS)))
 
-	authmiddleware := authorization.New(authorization.WithCookieName(s.authCookieName),
-		authorization.WithAuthorizationHeader(), authorization.WithHeaderName(s.authHeaderName))
+	authOpts := []authorization.Option{
+		authorization.WithCookieName(s.authCookieName),
+		authorization.WithAuthorizationHeader(),
+		authorization.WithHeaderName(s.authHeaderName),
+		authorization.WithClaimsMapper(config),
+		authorization.WithAPIKeyHeader(s.apiKeyHeaderName, config),
+	}
+	if s.verifier != nil {
+		authOpts = append(authOpts, authorization.WithVerifier(s.verifier))
+	}
+	if s.clientCAs != nil {
+		authOpts = append(authOpts,
+			authorization.WithClientCertificates(s.clientCAs),
+			authorization.WithForwardedClientCertificates(s.clientCertHeader, s.trustedProxies),
+		)
+	}
+
+	for _, tokenAuth := range s.tokenAuths {
+		authOpts = append(authOpts, authorization.WithTokenAuthenticator(tokenAuth))
+	}
+
+	authmiddleware := authorization.New(authOpts...)
 
 	api := v1beta1.New(s.router,
 		v1beta1_ds.New(config, cloudfront),
 	)
 
 	api.Use(authmiddleware)
+	api.Use(s.csrf.Handler)
 
 	return s.router, nil
 }
 
 func (s *Server) handleRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := s.template.ExecuteTemplate(w, "index.html", nil); err != nil {
+		token, err := s.csrf.Token(w, r)
+		if err != nil {
+			log.WithError(err).Error("error issuing csrf token")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			CSRFToken  string
+			CSRFHeader string
+		}{
+			CSRFToken:  token,
+			CSRFHeader: s.csrf.HeaderName(),
+		}
+
+		if err := s.template.ExecuteTemplate(w, "index.html", data); err != nil {
 			log.WithError(err).Error("error executing template")
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
@@ -5,25 +5,39 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/kanopy-platform/cdnvalidator/internal/config"
+	"github.com/kanopy-platform/cdnvalidator/internal/jwt"
 	"github.com/kanopy-platform/cdnvalidator/pkg/aws/cloudfront"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testHandler http.Handler
 
 func TestMain(m *testing.M) {
-	var err error
-
-	config := config.New()
-	cloudfront, err := cloudfront.New()
+	config, err := config.NewTestConfigWithYaml([]byte(`
+distributions:
+  dis1:
+    id: "123"
+    prefix: /foo
+entitlements:
+  grp1:
+    - dis1
+  cn:deployer:
+    - dis1
+  system:serviceaccount:web:deployer:
+    - dis1
+`))
 	if err != nil {
 		os.Exit(1)
 	}
 
-	testHandler, err = New(config, cloudfront)
+	cloudfront := cloudfront.NewTestCloudfrontClient(&cloudfront.MockCloudFrontClient{Status: "InProgress"})
+
+	testHandler, err = New(config, cloudfront, WithAuthCookieName("auth_token"))
 	if err != nil {
 		os.Exit(1)
 	}
@@ -36,6 +50,13 @@ func TestHandleRoot(t *testing.T) {
 	w := httptest.NewRecorder()
 	testHandler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the page carries the csrf token issued in the cookie
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "csrf_token", cookies[0].Name)
+		assert.Contains(t, w.Body.String(), `<meta name="csrf-token" content="`+cookies[0].Value+`" data-header="X-CSRF-Token">`)
+	}
 }
 
 func TestHandleHealthz(t *testing.T) {
@@ -52,3 +73,45 @@ func TestHandleHealthz(t *testing.T) {
 	assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
 	assert.Equal(t, want, got)
 }
+
+func TestCookieAuthenticationCSRF(t *testing.T) {
+	t.Parallel()
+
+	token, err := jwt.NewTestJWTWithClaims(jwt.Claims{Groups: []string{"grp1"}})
+	require.NoError(t, err)
+
+	invalidate := func(csrfToken string) int {
+		req := httptest.NewRequest("POST", "/api/v1beta1/distributions/dis1/invalidations", strings.NewReader(`{"paths": ["/foo/index.html"]}`))
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+		if csrfToken != "" {
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: csrfToken})
+			req.Header.Set("X-CSRF-Token", csrfToken)
+		}
+
+		w := httptest.NewRecorder()
+		testHandler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// browsers authenticated by the cookie alone must submit the csrf token
+	assert.Equal(t, http.StatusForbidden, invalidate(""))
+	assert.Equal(t, http.StatusCreated, invalidate("t1"))
+}
+
+func TestReservedClaims(t *testing.T) {
+	t.Parallel()
+
+	// token groups named like client certificate or service account claims
+	// are not entitled
+	for _, group := range []string{"cn:deployer", "system:serviceaccount:web:deployer"} {
+		token, err := jwt.NewTestJWTWithClaims(jwt.Claims{Groups: []string{"grp2", group}})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/api/v1beta1/distributions/dis1/invalidations", strings.NewReader(`{"paths": ["/foo/index.html"]}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		testHandler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, group)
+	}
+}
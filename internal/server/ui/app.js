@@ -3,6 +3,8 @@
 let itemCount = 0;
 const apiPrefix = "/api/v1beta1/distributions";
 const itemPrefix = "item-";
+// details of the distributions the user is entitled to, by name
+let distributionDetails = {};
 
 async function getJson(url = "") {
     const response = await fetch(url, {
@@ -27,12 +29,21 @@ async function getJson(url = "") {
     throw new Error(message);
 }
 
+function csrfHeaders() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    if (!meta) {
+        return {};
+    }
+    return {[meta.dataset.header]: meta.content};
+}
+
 async function postJson(url = "", postData = {}) {
     const response = await fetch(url, {
         method: "POST",
         headers: {
         "Accept": "application/json",
         "Content-Type": "application/json",
+        ...csrfHeaders(),
         },
         body: JSON.stringify(postData),
     })
@@ -117,10 +128,17 @@ async function populateDistributionDropdowns() {
             let option = document.createElement("option");
             option.text = distribution;
             option.value = distribution;
+            let details = data.details[distribution];
+            option.title = (details.pathPrefixes || [])
+                .concat((details.pathPatterns || []).map(pattern => pattern.glob || pattern.regex))
+                .join(", ");
 
             createInvalidationDropdown.appendChild(option.cloneNode(true));
             getInvalidationDropdown.appendChild(option.cloneNode(true));
         })
+        distributionDetails = data.details;
+        showDistributionInfo("create-invalidation-distribution");
+        showDistributionInfo("get-invalidation-distribution");
     })
     .catch(error => {
         appendOutput("Error Populating Distributions", "", error.message);
@@ -129,6 +147,69 @@ async function populateDistributionDropdowns() {
     document.getElementById("loading").style.visibility="hidden";
 }
 
+// showDistributionInfo describes the distribution selected in the dropdown
+// below it. Metadata is set as text so configuration cannot inject markup.
+function showDistributionInfo(dropdownId) {
+    let info = document.getElementById(dropdownId + "-info");
+    info.replaceChildren();
+
+    let details = distributionDetails[document.getElementById(dropdownId).value];
+    if (details === undefined) {
+        return;
+    }
+
+    function addLine(label, ...nodes) {
+        let line = document.createElement("div");
+        let labelNode = document.createElement("b");
+        labelNode.textContent = label + ": ";
+        line.append(labelNode, ...nodes);
+        info.appendChild(line);
+    }
+
+    if (details.description) {
+        let line = document.createElement("div");
+        line.textContent = details.description;
+        info.appendChild(line);
+    }
+    if (details.owners) {
+        addLine("Owners", details.owners.join(", "));
+    }
+    if (details.contact) {
+        let contact = document.createTextNode(details.contact);
+        if (details.contact.includes("@")) {
+            contact = document.createElement("a");
+            contact.href = "mailto:" + details.contact;
+            contact.textContent = details.contact;
+        }
+        addLine("Contact", contact);
+    }
+    if (details.links) {
+        let links = [];
+        Object.keys(details.links).sort().forEach(name => {
+            let link = document.createElement("a");
+            link.href = details.links[name];
+            link.target = "_blank";
+            link.rel = "noopener noreferrer";
+            link.textContent = name;
+            links.push(link, " ");
+        })
+        addLine("Links", ...links);
+    }
+    if (details.discovered) {
+        addLine("Source", "discovered from Cloudfront tags");
+    }
+    if (details.labels) {
+        let labels = [];
+        Object.keys(details.labels).sort().forEach(key => {
+            let badge = document.createElement("span");
+            badge.className = "badge badge-secondary mr-1";
+            badge.textContent = details.labels[key] === "" ? key : key + "=" + details.labels[key];
+            labels.push(badge);
+        })
+        addLine("Labels", ...labels);
+    }
+}
+
 async function createInvalidation() {
     // construct the POST request
     let distribution = document.getElementById("create-invalidation-distribution").value;
@@ -0,0 +1,173 @@
+package cloudfront
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cf "github.com/aws/aws-sdk-go-v2/service/cloudfront"
+)
+
+const (
+	// TagPrefix prefixes the tags describing a vanity distribution
+	TagPrefix = "cdnvalidator/"
+	// TagName is the tag holding the vanity name, distributions without it are ignored
+	TagName = TagPrefix + "name"
+	// TagPrefixes is the tag holding the space separated path prefixes
+	TagPrefixes = TagPrefix + "prefix"
+	// TagDescription is the tag holding the description
+	TagDescription = TagPrefix + "description"
+	// TagOwners is the tag holding the space separated owners
+	TagOwners = TagPrefix + "owners"
+	// TagContact is the tag holding the contact of the owners
+	TagContact = TagPrefix + "contact"
+)
+
+// InvalidTagsError reports the Cloudfront distributions skipped by
+// discovery because their tags do not describe a vanity distribution
+type InvalidTagsError struct {
+	Skipped []string
+}
+
+func (e *InvalidTagsError) Error() string {
+	return "skipped cloudfront distributions with invalid tags: " + strings.Join(e.Skipped, "; ")
+}
+
+// DiscoverDistributions lists the Cloudfront distributions and returns the
+// vanity distributions described by their tags, sorted by name.  A vanity
+// name may only be tagged on a single Cloudfront distribution.  Tags which
+// do not describe a vanity distribution are skipped and reported with an
+// InvalidTagsError along with the distributions discovered.
+func (c *Client) DiscoverDistributions(ctx context.Context) ([]DiscoveredDistribution, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	discovered := []DiscoveredDistribution{}
+	skipped := []string{}
+	owners := make(map[string][]string)
+
+	paginator := cf.NewListDistributionsPaginator(c.cfClient, &cf.ListDistributionsInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if output.DistributionList == nil {
+			break
+		}
+
+		for _, summary := range output.DistributionList.Items {
+			tags, err := c.distributionTags(ctx, aws.ToString(summary.ARN))
+			if err != nil {
+				return nil, err
+			}
+
+			name := tags[TagName]
+			if name == "" {
+				continue
+			}
+
+			id := aws.ToString(summary.Id)
+			owners[name] = append(owners[name], id)
+
+			distribution := DiscoveredDistribution{
+				Name:           name,
+				DistributionID: id,
+				Prefixes:       strings.Fields(tags[TagPrefixes]),
+				Description:    tags[TagDescription],
+				Owners:         strings.Fields(tags[TagOwners]),
+				Contact:        tags[TagContact],
+			}
+			if err := distribution.validate(); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s: %v", id, err))
+				continue
+			}
+
+			discovered = append(discovered, distribution)
+		}
+	}
+
+	// a vanity name tagged on several distributions is ambiguous
+	for name, ids := range owners {
+		if len(ids) > 1 {
+			skipped = append(skipped, fmt.Sprintf("vanity distribution %s is tagged on cloudfront distributions %s", name, strings.Join(ids, " and ")))
+		}
+	}
+
+	unique := discovered[:0]
+	for _, distribution := range discovered {
+		if len(owners[distribution.Name]) == 1 {
+			unique = append(unique, distribution)
+		}
+	}
+	discovered = unique
+
+	sort.Slice(discovered, func(i, j int) bool {
+		return discovered[i].Name < discovered[j].Name
+	})
+
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		return discovered, &InvalidTagsError{Skipped: skipped}
+	}
+
+	return discovered, nil
+}
+
+// validate checks the tags a vanity distribution is described by
+func (d *DiscoveredDistribution) validate() error {
+	if len(d.Prefixes) == 0 {
+		return fmt.Errorf("vanity distribution %s has no %s tag", d.Name, TagPrefixes)
+	}
+
+	for _, prefix := range d.Prefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("vanity distribution %s prefix %s must be an absolute path", d.Name, prefix)
+		}
+	}
+
+	return nil
+}
+
+// distributionTags returns the cdnvalidator tags of a Cloudfront distribution
+func (c *Client) distributionTags(ctx context.Context, arn string) (map[string]string, error) {
+	output, err := c.cfClient.ListTagsForResource(ctx, &cf.ListTagsForResourceInput{
+		Resource: aws.String(arn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tags of %s: %w", arn, err)
+	}
+
+	tags := make(map[string]string)
+	if output.Tags == nil {
+		return tags, nil
+	}
+
+	for _, tag := range output.Tags.Items {
+		if key := aws.ToString(tag.Key); strings.HasPrefix(key, TagPrefix) {
+			tags[key] = strings.TrimSpace(aws.ToString(tag.Value))
+		}
+	}
+
+	return tags, nil
+}
+
+// WatchDistributions discovers the vanity distributions every interval
+// until ctx is done, passing the outcome of every discovery to discovered
+func (c *Client) WatchDistributions(ctx context.Context, interval time.Duration, discovered func([]DiscoveredDistribution, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			discovered(c.DiscoverDistributions(ctx))
+		}
+	}
+}
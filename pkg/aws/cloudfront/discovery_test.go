@@ -0,0 +1,116 @@
+package cloudfront
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testARN(id string) string {
+	return "arn:aws:cloudfront::123456789012:distribution/" + id
+}
+
+func TestDiscoverDistributions(t *testing.T) {
+	t.Parallel()
+
+	mock := &MockCloudFrontClient{
+		Distributions: []types.DistributionSummary{
+			NewTestDistributionSummary("E1"),
+			NewTestDistributionSummary("E2"),
+			NewTestDistributionSummary("E3"),
+		},
+		Tags: map[string]map[string]string{
+			testARN("E1"): {
+				TagName:        "sandbox",
+				TagPrefixes:    " /my/path  /other/path ",
+				TagDescription: "Sandbox site",
+				TagOwners:      "team-a team-b",
+				TagContact:     "team-a@example.com",
+				"environment":  "prod",
+			},
+			// untagged distributions are not discovered
+			testARN("E2"): {"environment": "prod"},
+			testARN("E3"): {TagName: "docs", TagPrefixes: "/docs"},
+		},
+		// distributions are listed over several pages
+		PageSize: 2,
+	}
+
+	client := NewTestCloudfrontClient(mock)
+	client.timeout = time.Minute
+
+	discovered, err := client.DiscoverDistributions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []DiscoveredDistribution{
+		{Name: "docs", DistributionID: "E3", Prefixes: []string{"/docs"}, Owners: []string{}},
+		{
+			Name:           "sandbox",
+			DistributionID: "E1",
+			Prefixes:       []string{"/my/path", "/other/path"},
+			Description:    "Sandbox site",
+			Owners:         []string{"team-a", "team-b"},
+			Contact:        "team-a@example.com",
+		},
+	}, discovered)
+
+	// a vanity name may only be tagged on one distribution, invalid tags
+	// are skipped without affecting the other distributions
+	mock.Tags[testARN("E2")] = map[string]string{TagName: "sandbox", TagPrefixes: "/sandbox"}
+	mock.Distributions = append(mock.Distributions, NewTestDistributionSummary("E4"), NewTestDistributionSummary("E5"))
+	mock.Tags[testARN("E4")] = map[string]string{TagName: "api"}
+	mock.Tags[testARN("E5")] = map[string]string{TagName: "assets", TagPrefixes: "/assets assets/team-a"}
+
+	discovered, err = client.DiscoverDistributions(context.Background())
+	assert.Equal(t, []DiscoveredDistribution{{Name: "docs", DistributionID: "E3", Prefixes: []string{"/docs"}, Owners: []string{}}}, discovered)
+	assert.EqualError(t, err, "skipped cloudfront distributions with invalid tags: "+
+		"E4: vanity distribution api has no cdnvalidator/prefix tag; "+
+		"E5: vanity distribution assets prefix assets/team-a must be an absolute path; "+
+		"vanity distribution sandbox is tagged on cloudfront distributions E1 and E2")
+
+	var invalidTags *InvalidTagsError
+	assert.ErrorAs(t, err, &invalidTags)
+
+	mock.Err = errors.New("mock cloudfront error")
+	discovered, err = client.DiscoverDistributions(context.Background())
+	assert.Nil(t, discovered)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &invalidTags))
+}
+
+func TestWatchDistributions(t *testing.T) {
+	t.Parallel()
+
+	mock := &MockCloudFrontClient{
+		Distributions: []types.DistributionSummary{NewTestDistributionSummary("E1")},
+		Tags:          map[string]map[string]string{testARN("E1"): {TagName: "sandbox", TagPrefixes: "/my/path"}},
+	}
+
+	client := NewTestCloudfrontClient(mock)
+	client.timeout = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan []DiscoveredDistribution)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.WatchDistributions(ctx, 10*time.Millisecond, func(discovered []DiscoveredDistribution, err error) {
+			assert.NoError(t, err)
+			select {
+			case results <- discovered:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	discovered := <-results
+	require.Len(t, discovered, 1)
+	assert.Equal(t, "sandbox", discovered[0].Name)
+
+	cancel()
+	<-done
+}
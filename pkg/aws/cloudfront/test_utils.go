@@ -2,6 +2,8 @@ package cloudfront
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -23,6 +25,11 @@ type MockCloudFrontClient struct {
 	// only used by GetInvalidation
 	Paths           []string
 	CallerReference string
+	// only used by ListDistributions and ListTagsForResource, distributions
+	// are listed PageSize at a time and their Tags are looked up by ARN
+	Distributions []types.DistributionSummary
+	Tags          map[string]map[string]string
+	PageSize      int
 }
 
 func (m *MockCloudFrontClient) CreateInvalidation(ctx context.Context, params *cf.CreateInvalidationInput, optFns ...func(*cf.Options)) (*cf.CreateInvalidationOutput, error) {
@@ -71,3 +78,57 @@ func (m *MockCloudFrontClient) GetInvalidation(ctx context.Context, params *cf.G
 
 	return output, nil
 }
+
+func (m *MockCloudFrontClient) ListDistributions(ctx context.Context, params *cf.ListDistributionsInput, optFns ...func(*cf.Options)) (*cf.ListDistributionsOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	start := 0
+	if params.Marker != nil {
+		start, _ = strconv.Atoi(*params.Marker)
+	}
+
+	end := len(m.Distributions)
+	if m.PageSize > 0 && start+m.PageSize < end {
+		end = start + m.PageSize
+	}
+
+	list := &types.DistributionList{
+		Items:       m.Distributions[start:end],
+		IsTruncated: aws.Bool(end < len(m.Distributions)),
+		Quantity:    aws.Int32(int32(end - start)),
+	}
+	if end < len(m.Distributions) {
+		list.NextMarker = aws.String(strconv.Itoa(end))
+	}
+
+	return &cf.ListDistributionsOutput{DistributionList: list}, nil
+}
+
+func (m *MockCloudFrontClient) ListTagsForResource(ctx context.Context, params *cf.ListTagsForResourceInput, optFns ...func(*cf.Options)) (*cf.ListTagsForResourceOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	tags, ok := m.Tags[aws.ToString(params.Resource)]
+	if !ok {
+		return nil, fmt.Errorf("no such resource %s", aws.ToString(params.Resource))
+	}
+
+	items := []types.Tag{}
+	for key, value := range tags {
+		items = append(items, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return &cf.ListTagsForResourceOutput{Tags: &types.Tags{Items: items}}, nil
+}
+
+// NewTestDistributionSummary returns the summary of a Cloudfront distribution
+// listed by MockCloudFrontClient
+func NewTestDistributionSummary(id string) types.DistributionSummary {
+	return types.DistributionSummary{
+		Id:  aws.String(id),
+		ARN: aws.String("arn:aws:cloudfront::123456789012:distribution/" + id),
+	}
+}
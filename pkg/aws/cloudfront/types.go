@@ -12,6 +12,8 @@ import (
 type cfClientAPI interface {
 	CreateInvalidation(ctx context.Context, params *cf.CreateInvalidationInput, optFns ...func(*cf.Options)) (*cf.CreateInvalidationOutput, error)
 	GetInvalidation(ctx context.Context, params *cf.GetInvalidationInput, optFns ...func(*cf.Options)) (*cf.GetInvalidationOutput, error)
+	ListDistributions(ctx context.Context, params *cf.ListDistributionsInput, optFns ...func(*cf.Options)) (*cf.ListDistributionsOutput, error)
+	ListTagsForResource(ctx context.Context, params *cf.ListTagsForResourceInput, optFns ...func(*cf.Options)) (*cf.ListTagsForResourceOutput, error)
 }
 
 type Client struct {
@@ -39,3 +41,20 @@ type GetInvalidationOutput struct {
 	CreateTime     time.Time
 	Paths          []string
 }
+
+// DiscoveredDistribution is a vanity distribution described by the tags of
+// a Cloudfront distribution
+type DiscoveredDistribution struct {
+	// Name is the vanity name of the cdnvalidator/name tag
+	Name string
+	// DistributionID is the ID of the tagged Cloudfront distribution
+	DistributionID string
+	// Prefixes are the space separated paths of the cdnvalidator/prefix tag
+	Prefixes []string
+	// Description is the value of the cdnvalidator/description tag
+	Description string
+	// Owners are the space separated owners of the cdnvalidator/owners tag
+	Owners []string
+	// Contact is the value of the cdnvalidator/contact tag
+	Contact string
+}
@@ -0,0 +1,43 @@
+package s3
+
+import (
+	"time"
+)
+
+type Option func(c *Client)
+
+func WithAWSRegion(region string) Option {
+	return func(c *Client) {
+		if region != "" {
+			c.region = region
+		}
+	}
+}
+
+func WithStaticCredentials(key string, secret string) Option {
+	return func(c *Client) {
+		c.staticCredentials.key = key
+		c.staticCredentials.secret = secret
+	}
+}
+
+func WithTimeout(t time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = t
+	}
+}
+
+// WithEndpoint sends requests to an S3 compatible endpoint with path style
+// addressing instead of AWS
+func WithEndpoint(url string) Option {
+	return func(c *Client) {
+		c.endpoint = url
+	}
+}
+
+// WithMaxObjectSize bounds the size of the objects read by GetObject
+func WithMaxObjectSize(size int64) Option {
+	return func(c *Client) {
+		c.maxObjectSize = size
+	}
+}
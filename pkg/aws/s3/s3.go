@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrNotModified is returned by GetObject when the object still has the ETag
+// of the previous request
+var ErrNotModified = errors.New("not modified")
+
+// defaultMaxObjectSize bounds the objects GetObject reads into memory, 10 MiB
+const defaultMaxObjectSize = 10 << 20
+
+func New(opts ...Option) (*Client, error) {
+	client := &Client{}
+
+	// default options
+	o := []Option{
+		WithAWSRegion("us-east-1"),
+		WithTimeout(30 * time.Second),
+		WithMaxObjectSize(defaultMaxObjectSize),
+	}
+
+	opts = append(o, opts...)
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	// Construct AWS Config Options
+	awsCfgOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(client.region),
+	}
+	if client.staticCredentials.key != "" && client.staticCredentials.secret != "" {
+		awsCfgOptions = append(awsCfgOptions, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(client.staticCredentials.key, client.staticCredentials.secret, "")))
+	}
+
+	// By default, if no StaticCredentials are provided, LoadDefaultConfig will use environment variables
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN
+	cfg, err := config.LoadDefaultConfig(context.Background(), awsCfgOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	client.s3Client = awss3.NewFromConfig(cfg, func(o *awss3.Options) {
+		if client.endpoint != "" {
+			o.EndpointResolver = awss3.EndpointResolverFromURL(client.endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return client, nil
+}
+
+// GetObject returns the content and ETag of an object, or ErrNotModified
+// when etag is not empty and the object still has it.  Objects larger than
+// the maximum object size are rejected.
+func (c *Client) GetObject(ctx context.Context, bucket string, key string, etag string) (*GetObjectOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	input := &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	output, err := c.s3Client.GetObject(ctx, input)
+	if err != nil {
+		var responseErr *smithyhttp.ResponseError
+		if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotModified {
+			return nil, ErrNotModified
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(output.Body, c.maxObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.maxObjectSize {
+		return nil, fmt.Errorf("object %s exceeds %d bytes", key, c.maxObjectSize)
+	}
+
+	response := &GetObjectOutput{
+		Body: body,
+		ETag: aws.ToString(output.ETag),
+	}
+
+	return response, nil
+}
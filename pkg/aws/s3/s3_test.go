@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves a single object with path style addressing
+func fakeS3(t *testing.T, body string, etag string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/bucket/config.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGetObject(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeS3(t, "distributions: {}\n", `"abc123"`)
+
+	client, err := New(WithEndpoint(srv.URL), WithStaticCredentials("key", "secret"))
+	require.NoError(t, err)
+
+	output, err := client.GetObject(context.Background(), "bucket", "config.yaml", "")
+	require.NoError(t, err)
+	assert.Equal(t, "distributions: {}\n", string(output.Body))
+	assert.Equal(t, `"abc123"`, output.ETag)
+
+	// unchanged since the previous request
+	_, err = client.GetObject(context.Background(), "bucket", "config.yaml", output.ETag)
+	assert.ErrorIs(t, err, ErrNotModified)
+
+	// changed since the previous request
+	output, err = client.GetObject(context.Background(), "bucket", "config.yaml", `"def456"`)
+	require.NoError(t, err)
+	assert.Equal(t, `"abc123"`, output.ETag)
+
+	_, err = client.GetObject(context.Background(), "bucket", "missing.yaml", "")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotModified)
+
+	client, err = New(WithEndpoint(srv.URL), WithStaticCredentials("key", "secret"), WithMaxObjectSize(8))
+	require.NoError(t, err)
+
+	_, err = client.GetObject(context.Background(), "bucket", "config.yaml", "")
+	assert.EqualError(t, err, "object config.yaml exceeds 8 bytes")
+}
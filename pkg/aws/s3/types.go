@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Defines the set of APIs from aws-sdk-go-v2/service/s3 required
+// This abstraction allows mocking these methods in _test.go
+type s3ClientAPI interface {
+	GetObject(ctx context.Context, params *awss3.GetObjectInput, optFns ...func(*awss3.Options)) (*awss3.GetObjectOutput, error)
+}
+
+type Client struct {
+	s3Client          s3ClientAPI
+	region            string
+	staticCredentials awsStaticCredentials
+	timeout           time.Duration
+	endpoint          string
+	maxObjectSize     int64
+}
+
+type awsStaticCredentials struct {
+	key    string
+	secret string
+}
+
+type GetObjectOutput struct {
+	Body []byte
+	ETag string
+}